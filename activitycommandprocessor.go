@@ -0,0 +1,55 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	// The activity log takes a few minutes to ingest events, so --follow re-reads this window and skips events already printed
+	ActivityLogFollowOverlap = 15 * time.Minute
+)
+
+func (processor *CommandProcessor) processActivityCommand(filter ActivityLogFilter, maxContinuation int, outputFormat string) {
+	events := processor.azureClient.getActivityLogEvents(filter, maxContinuation)
+	printOutput(outputFormat, getActivityLogEventsOutputTable(events), events)
+}
+
+// Poll the activity log and print the new events until the process is interrupted
+func (processor *CommandProcessor) processActivityFollowCommand(filter ActivityLogFilter, maxContinuation int, pollInterval time.Duration, outputFormat string) {
+	seenEventDataIds := make(map[string]time.Time)
+	isFirstBatch := true
+
+	for {
+		filter.End = time.Now().UTC()
+
+		newEvents := make([]ActivityLogEvent, 0)
+		for _, event := range processor.azureClient.getActivityLogEvents(filter, maxContinuation) {
+			if _, ok := seenEventDataIds[event.EventDataId]; ok {
+				continue
+			}
+
+			seenEventDataIds[event.EventDataId] = event.EventTimestamp
+			newEvents = append(newEvents, event)
+		}
+
+		if len(newEvents) > 0 || isFirstBatch {
+			values := make([]interface{}, 0)
+			for _, event := range newEvents {
+				values = append(values, event)
+			}
+
+			printOutputBatch(outputFormat, getActivityLogEventsOutputTable(newEvents), values, isFirstBatch)
+			isFirstBatch = false
+		}
+
+		// Only look back far enough to pick up late events, and forget the events that fell out of the window
+		filter.Start = filter.End.Add(-ActivityLogFollowOverlap)
+		for eventDataId, eventTimestamp := range seenEventDataIds {
+			if eventTimestamp.Before(filter.Start) {
+				delete(seenEventDataIds, eventDataId)
+			}
+		}
+
+		time.Sleep(pollInterval)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ActivityLogApiVersion = "2015-04-01"
+)
+
+type ActivityLogEvent struct {
+	Id                string                 `json:"id"`
+	EventDataId       string                 `json:"eventDataId"`
+	CorrelationId     string                 `json:"correlationId"`
+	Caller            string                 `json:"caller"`
+	Level             string                 `json:"level"`
+	Category          LocalizableString      `json:"category"`
+	OperationName     LocalizableString      `json:"operationName"`
+	Status            LocalizableString      `json:"status"`
+	SubStatus         LocalizableString      `json:"subStatus"`
+	EventTimestamp    time.Time              `json:"eventTimestamp"`
+	ResourceGroupName string                 `json:"resourceGroupName"`
+	ResourceId        string                 `json:"resourceId"`
+	ResourceType      LocalizableString      `json:"resourceType"`
+	Properties        map[string]interface{} `json:"properties"`
+}
+
+type ActivityLogFilter struct {
+	Start         time.Time
+	End           time.Time
+	ResourceGroup string
+	ResourceId    string
+	Caller        string
+	Status        string
+}
+
+// Parse the RFC 3339 start and end of the time range.  The end defaults to now and the start defaults to lookback before the end.
+func parseActivityLogTimeRange(start string, end string, lookback time.Duration) (time.Time, time.Time, error) {
+	endTime := time.Now().UTC()
+	if len(end) > 0 {
+		var err error
+		endTime, err = time.Parse(time.RFC3339, end)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("Error parsing end time: %s", err)
+		}
+	}
+
+	startTime := endTime.Add(-lookback)
+	if len(start) > 0 {
+		var err error
+		startTime, err = time.Parse(time.RFC3339, start)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("Error parsing start time: %s", err)
+		}
+	}
+
+	return startTime, endTime, nil
+}
+
+// Query the activity log of the subscription, following nextLink continuation tokens.  The events are sorted by
+// event timestamp, oldest first.
+func (azureClient *AzureClient) getActivityLogEvents(filter ActivityLogFilter, maxContinuation int) []ActivityLogEvent {
+	query := url.Values{
+		"api-version": {ActivityLogApiVersion},
+		"$filter":     {filter.getODataFilter()},
+	}
+
+	targetUrl := fmt.Sprintf(
+		"/subscriptions/%s/providers/microsoft.insights/eventtypes/management/values?%s",
+		azureClient.config.Credentials.SubscriptionID,
+		query.Encode(),
+	)
+
+	events := make([]ActivityLogEvent, 0)
+	for _, value := range azureClient.getPagedValues(targetUrl, maxContinuation) {
+		var event ActivityLogEvent
+		err := json.Unmarshal(value, &event)
+		if err != nil {
+			log.Fatalf("Error unmarshalling activity log response body: %v", err)
+		}
+
+		if filter.isMatch(event) {
+			events = append(events, event)
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].EventTimestamp.Before(events[j].EventTimestamp)
+	})
+
+	return events
+}
+
+// The activity log API accepts the time range plus at most one of resource group or resource ID on the server side
+func (filter *ActivityLogFilter) getODataFilter() string {
+	odataFilter := fmt.Sprintf(
+		"eventTimestamp ge '%s' and eventTimestamp le '%s'",
+		filter.Start.UTC().Format(time.RFC3339),
+		filter.End.UTC().Format(time.RFC3339),
+	)
+
+	// Single quotes are doubled in OData string literals
+	if len(filter.ResourceId) > 0 {
+		odataFilter += fmt.Sprintf(" and resourceUri eq '%s'", strings.Replace(normalizeResourceId(filter.ResourceId), "'", "''", -1))
+	} else if len(filter.ResourceGroup) > 0 {
+		odataFilter += fmt.Sprintf(" and resourceGroupName eq '%s'", strings.Replace(filter.ResourceGroup, "'", "''", -1))
+	}
+
+	return odataFilter
+}
+
+// The caller and status filters are applied on the client side
+func (filter *ActivityLogFilter) isMatch(event ActivityLogEvent) bool {
+	if len(filter.Caller) > 0 && !strings.EqualFold(event.Caller, filter.Caller) {
+		return false
+	}
+
+	if len(filter.Status) > 0 && !strings.EqualFold(event.Status.Value, filter.Status) {
+		return false
+	}
+
+	return true
+}
+
+func getActivityLogEventsOutputTable(events []ActivityLogEvent) OutputTable {
+	table := OutputTable{
+		Headers: []string{"EventTimestamp", "Level", "Status", "OperationName", "Caller", "ResourceId", "CorrelationId"},
+	}
+
+	for _, event := range events {
+		table.addRow(
+			event.EventTimestamp.UTC().Format(time.RFC3339),
+			event.Level,
+			event.Status.Value,
+			event.OperationName.Value,
+			event.Caller,
+			event.ResourceId,
+			event.CorrelationId,
+		)
+	}
+
+	return table
+}
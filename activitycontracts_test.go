@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetODataFilter(t *testing.T) {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	timeRange := "eventTimestamp ge '2026-01-02T03:04:05Z' and eventTimestamp le '2026-01-03T03:04:05Z'"
+
+	tests := []struct {
+		resourceGroup string
+		resourceId    string
+		expected      string
+	}{
+		{"", "", timeRange},
+		{"rg1", "", timeRange + " and resourceGroupName eq 'rg1'"},
+		{"rg1' or resourceGroupName eq 'rg2", "", timeRange + " and resourceGroupName eq 'rg1'' or resourceGroupName eq ''rg2'"},
+		{"rg1", "subscriptions/sub1/resourceGroups/rg1/providers/A/b/it's/", timeRange + " and resourceUri eq '/subscriptions/sub1/resourceGroups/rg1/providers/A/b/it''s'"},
+	}
+
+	for _, test := range tests {
+		filter := ActivityLogFilter{Start: start, End: start.Add(24 * time.Hour), ResourceGroup: test.resourceGroup, ResourceId: test.resourceId}
+		if odataFilter := filter.getODataFilter(); odataFilter != test.expected {
+			t.Errorf("%q, %q: expected %s, got %s", test.resourceGroup, test.resourceId, test.expected, odataFilter)
+		}
+	}
+}
@@ -9,18 +9,31 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-type ArmResourceListResponse struct {
-	Values   []ArmResource `json:"value"`
-	NextLink string        `json:"nextLink"`
+type ArmListResponse struct {
+	Values   []json.RawMessage `json:"value"`
+	NextLink string            `json:"nextLink"`
+}
+
+type ArmErrorResponse struct {
+	Error *ArmError `json:"error"`
 }
 
 type ArmResource struct {
-	Id       string         `json:"id"`
-	Location string         `json:"location"`
-	Name     string         `json:"name"`
-	Type     string         `json:"type"`
-	Kind     string         `json:"kind"`
-	Sku      ArmResourceSku `json:"sku"`
+	Id       string            `json:"id"`
+	Location string            `json:"location"`
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	Kind     string            `json:"kind"`
+	Sku      ArmResourceSku    `json:"sku"`
+	Tags     map[string]string `json:"tags"`
+}
+
+type ArmSubscription struct {
+	Id             string `json:"id"`
+	SubscriptionId string `json:"subscriptionId"`
+	DisplayName    string `json:"displayName"`
+	State          string `json:"state"`
+	TenantId       string `json:"tenantId"`
 }
 
 type ArmResourceSku struct {
@@ -29,6 +42,55 @@ type ArmResourceSku struct {
 	Tier string `json:"tier"`
 }
 
+// Selects Azure resources by resource type, kind, resource group, location and tags.  Empty criteria match every resource.
+// A tag criterion with value * matches any value.
+type ArmResourceFilter struct {
+	ResourceType  string
+	Kind          string
+	ResourceGroup string
+	Location      string
+	Tags          map[string]string
+}
+
+func (filter *ArmResourceFilter) isMatch(armResource ArmResource) bool {
+	if len(filter.ResourceType) > 0 && !strings.EqualFold(armResource.Type, filter.ResourceType) {
+		return false
+	}
+
+	if len(filter.Kind) > 0 && !strings.EqualFold(armResource.Kind, filter.Kind) {
+		return false
+	}
+
+	if len(filter.ResourceGroup) > 0 {
+		resourceGroupName, _ := armResource.getResourceGroupName()
+		if !strings.EqualFold(resourceGroupName, filter.ResourceGroup) {
+			return false
+		}
+	}
+
+	if len(filter.Location) > 0 && normalizeLocation(armResource.Location) != normalizeLocation(filter.Location) {
+		return false
+	}
+
+	for key, value := range filter.Tags {
+		tagValue, ok := armResource.getTagValue(key)
+		if !ok || (value != "*" && !strings.EqualFold(tagValue, value)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (filter *ArmResourceFilter) filter(armResources []ArmResource) []ArmResource {
+	var filteredArmResources []ArmResource
+	From(armResources).WhereT(func(r ArmResource) bool {
+		return filter.isMatch(r)
+	}).ToSlice(&filteredArmResources)
+
+	return filteredArmResources
+}
+
 func (armResource *ArmResource) getResourceGroupName() (string, error) {
 	armResourceIdParts := strings.Split(armResource.Id, "/")
 	for index, armResourceIdPart := range armResourceIdParts {
@@ -45,6 +107,18 @@ func (armResource *ArmResource) getResourceGroupName() (string, error) {
 	return "", fmt.Errorf("Unable to find resource group")
 }
 
+func (armResource *ArmResource) getSubscriptionId() (string, error) {
+	armResourceIdParts := strings.Split(armResource.Id, "/")
+	for index, armResourceIdPart := range armResourceIdParts {
+		// Subscription ID is the next segment after 'subscriptions'
+		if strings.EqualFold(armResourceIdPart, "subscriptions") && index < len(armResourceIdParts)-1 {
+			return armResourceIdParts[index+1], nil
+		}
+	}
+
+	return "", fmt.Errorf("Unable to find subscription")
+}
+
 func (armResource *ArmResource) getResourceName() string {
 	resourceName := ""
 	armResourceIdParts := strings.Split(armResource.Id, "/")
@@ -69,6 +143,27 @@ func (armResource *ArmResource) getResourceName() string {
 	return resourceName
 }
 
+func convertToArmSubscription(body []byte) ArmSubscription {
+	var armSubscription ArmSubscription
+	err := json.Unmarshal(body, &armSubscription)
+	if err != nil {
+		log.Fatalf("Error unmarshalling ARM subscription response body: %v", err)
+	}
+
+	return armSubscription
+}
+
+// Get the value of the tag.  Tag keys are case-insensitive in ARM.
+func (armResource *ArmResource) getTagValue(key string) (string, bool) {
+	for tagKey, tagValue := range armResource.Tags {
+		if strings.EqualFold(tagKey, key) {
+			return tagValue, true
+		}
+	}
+
+	return "", false
+}
+
 func getDistinctRegions(armResources []ArmResource) []string {
 	var regions []string
 	From(armResources).SelectT(
@@ -84,8 +179,20 @@ func getDistinctRegions(armResources []ArmResource) []string {
 	return regions
 }
 
-func convertToArmResourceListResponse(body []byte) ArmResourceListResponse {
-	var armResponse ArmResourceListResponse
+func getArmResourcesOutputTable(armResources []ArmResource) OutputTable {
+	table := OutputTable{
+		Headers: []string{"Id", "Name", "Type", "Kind", "Location", "SkuName", "SkuSize", "SkuTier"},
+	}
+
+	for _, armResource := range armResources {
+		table.addRow(armResource.Id, armResource.Name, armResource.Type, armResource.Kind, armResource.Location, armResource.Sku.Name, armResource.Sku.Size, armResource.Sku.Tier)
+	}
+
+	return table
+}
+
+func convertToArmListResponse(body []byte) ArmListResponse {
+	var armResponse ArmListResponse
 	err := json.Unmarshal(body, &armResponse)
 	if err != nil {
 		log.Fatalf("Error unmarshalling ARM reource response body: %v", err)
@@ -1,16 +1,24 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	DefaultAsyncOperationPollInterval = 5 * time.Second
+)
+
 type AzureClient struct {
 	client      *http.Client
 	config      *Config
@@ -82,27 +90,18 @@ func (azureClient *AzureClient) ensureAccessTokenSet() {
 }
 
 func (azureClient *AzureClient) sendHttpMessage(method string, url string) *http.Response {
-	azureClient.ensureAccessTokenSet()
-
-	if !strings.HasPrefix(url, "/") {
-		url += "/"
-	}
-
-	targetUrl := url
-	if !strings.HasPrefix(url, "https://") {
-		targetUrl = fmt.Sprintf("%s%s", azureClient.environment.armUrl, url)
-	}
-
-	log.Infof("Running %s %s\n", method, url)
-
-	log.Debugf("Executing %s %s\n", method, targetUrl)
+	return azureClient.sendJsonHttpMessage(method, url, nil)
+}
 
-	request, err := http.NewRequest(method, targetUrl, nil)
+// Send the HTTP message with the request body serialized as JSON.  No body is sent if requestBody is nil.
+func (azureClient *AzureClient) sendJsonHttpMessage(method string, url string, requestBody interface{}) *http.Response {
+	request, err := azureClient.newArmRequest(method, url, requestBody)
 	if err != nil {
 		log.Fatalf("Error creating HTTP request: %v", err)
 	}
 
-	request.Header.Set("Authorization", "Bearer "+azureClient.accessToken)
+	log.Infof("Running %s %s\n", method, request.URL.RequestURI())
+
 	response, err := azureClient.client.Do(request)
 	if err != nil {
 		log.Fatalf("Error sending HTTP request: %v", err)
@@ -113,51 +112,207 @@ func (azureClient *AzureClient) sendHttpMessage(method string, url string) *http
 	if response.StatusCode < 200 || response.StatusCode >= 300 {
 		log.Errorf("Error sending HTTP request with status code: %d\n", response.StatusCode)
 
-		// If unsuccessful HTTP status code, print out the ARM error response
+		// If unsuccessful HTTP status code, log the ARM error response
 		body, err := ioutil.ReadAll(response.Body)
 		if err != nil {
 			log.Fatalf("Error reading body of response: %v", err)
 		}
 
-		prettyPrintJson(body)
+		log.Error(getIndentedJson(body))
 	}
 
 	return response
 }
 
+// Send the HTTP message with a JSON request body and return the response body, returning an error instead of exiting
+// or logging the response when the request fails.  The error of an unsuccessful response has the status code and the
+// ARM error message.  Safe to call concurrently once the access token is set.
+func (azureClient *AzureClient) tryGetJsonResponseBody(method string, url string, requestBody interface{}) ([]byte, error) {
+	request, err := azureClient.newArmRequest(method, url, requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("Running %s %s\n", method, request.URL.RequestURI())
+
+	response, err := azureClient.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("Status code: %d\n", response.StatusCode)
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading body of response: %v", err)
+	}
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		var errorResponse ArmErrorResponse
+		if json.Unmarshal(body, &errorResponse) == nil && errorResponse.Error != nil && len(errorResponse.Error.Message) > 0 {
+			return nil, fmt.Errorf("status code %d: %s", response.StatusCode, errorResponse.Error.Message)
+		}
+
+		return nil, fmt.Errorf("status code %d", response.StatusCode)
+	}
+
+	return body, nil
+}
+
+// Create the request with the access token.  Relative URLs are sent to ARM.  Absolute URLs, such as nextLink
+// continuation tokens, are sent as-is.
+func (azureClient *AzureClient) newArmRequest(method string, url string, requestBody interface{}) (*http.Request, error) {
+	azureClient.ensureAccessTokenSet()
+
+	targetUrl := url
+	if !strings.HasPrefix(url, "https://") {
+		if !strings.HasPrefix(url, "/") {
+			url = "/" + url
+		}
+
+		targetUrl = fmt.Sprintf("%s%s", azureClient.environment.armUrl, url)
+	}
+
+	log.Debugf("Executing %s %s\n", method, targetUrl)
+
+	var requestBodyReader io.Reader
+	if requestBody != nil {
+		requestBodyJson, err := json.Marshal(requestBody)
+		if err != nil {
+			return nil, fmt.Errorf("Error serializing HTTP request body: %v", err)
+		}
+
+		log.Debugf("Request body: %s\n", requestBodyJson)
+		requestBodyReader = bytes.NewReader(requestBodyJson)
+	}
+
+	request, err := http.NewRequest(method, targetUrl, requestBodyReader)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Authorization", "Bearer "+azureClient.accessToken)
+	if requestBody != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	return request, nil
+}
+
+// Poll the Location header of an accepted (202) long running operation until it completes and return the final response body.
+// Responses other than 202 are already complete.
+func (azureClient *AzureClient) waitForAsyncOperation(response *http.Response) []byte {
+	for response.StatusCode == http.StatusAccepted {
+		location := response.Header.Get("Location")
+		response.Body.Close()
+		if len(location) == 0 {
+			return []byte{}
+		}
+
+		pollInterval := DefaultAsyncOperationPollInterval
+		if retryAfter, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil && retryAfter > 0 {
+			pollInterval = time.Duration(retryAfter) * time.Second
+		}
+
+		log.Debugf("Waiting %v for long running operation %s\n", pollInterval, location)
+		time.Sleep(pollInterval)
+
+		response = azureClient.sendHttpMessage("GET", location)
+	}
+
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		log.Fatalf("Error reading body of response: %v", err)
+	}
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		log.Fatalf("Long running operation failed with status code: %d", response.StatusCode)
+	}
+
+	return body
+}
+
 func (azureClient *AzureClient) getAzureResources(maxContinuation int) []ArmResource {
 	// Invoke Azure Resource Manager resource cache API to find all Azure resources on the subscription
 	armResourceSlice := make([]ArmResource, 0)
 	targetUrl := fmt.Sprintf(
-			"/subscriptions/%s/resources?api-version=%s",
-			azureClient.config.Credentials.SubscriptionID,
-			azureClient.environment.apiVersion,
-		)
+		"/subscriptions/%s/resources?api-version=%s",
+		azureClient.config.Credentials.SubscriptionID,
+		azureClient.environment.apiVersion,
+	)
+
+	for _, value := range azureClient.getPagedValues(targetUrl, maxContinuation) {
+		var armResource ArmResource
+		err := json.Unmarshal(value, &armResource)
+		if err != nil {
+			log.Fatalf("Error unmarshalling ARM reource response body: %v", err)
+		}
+
+		armResourceSlice = append(armResourceSlice, armResource)
+	}
+
+	return armResourceSlice
+}
+
+func (azureClient *AzureClient) getSubscription() ArmSubscription {
+	targetUrl := fmt.Sprintf(
+		"/subscriptions/%s?api-version=%s",
+		azureClient.config.Credentials.SubscriptionID,
+		azureClient.environment.apiVersion,
+	)
+
+	return convertToArmSubscription(azureClient.getResponseBody("GET", targetUrl))
+}
+
+// Perform GET against an ARM list API and return the raw elements of "value", following nextLink continuation tokens
+func (azureClient *AzureClient) getPagedValues(targetUrl string, maxContinuation int) []json.RawMessage {
+	values := make([]json.RawMessage, 0)
 
 	// Follow nextLink continuation tokens
 	i := 0
 	for len(targetUrl) > 0 && i <= maxContinuation {
 
 		targetUrl = func(getUrl string) string {
-			response := azureClient.sendHttpMessage("GET", getUrl)
-			defer response.Body.Close()
-			body, err := ioutil.ReadAll(response.Body)
-			if err != nil {
-				log.Fatalf("Error reading body of response: %v", err)
-			}
-
-			armResourceListResponse := convertToArmResourceListResponse(body)
-			for _, armResource := range armResourceListResponse.Values {
-				armResourceSlice = append(armResourceSlice, armResource)
-			}
-
-			return armResourceListResponse.NextLink
+			body := azureClient.getResponseBody("GET", getUrl)
+
+			armListResponse := convertToArmListResponse(body)
+			values = append(values, armListResponse.Values...)
+
+			return armListResponse.NextLink
 		}(targetUrl)
 
 		i++
 	}
 
-	return armResourceSlice
+	return values
+}
+
+// Send the HTTP message and return the response body
+func (azureClient *AzureClient) getResponseBody(method string, url string) []byte {
+	return azureClient.getJsonResponseBody(method, url, nil)
+}
+
+// Send the HTTP message with a JSON request body and return the response body
+func (azureClient *AzureClient) getJsonResponseBody(method string, url string, requestBody interface{}) []byte {
+	response := azureClient.sendJsonHttpMessage(method, url, requestBody)
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		log.Fatalf("Error reading body of response: %v", err)
+	}
+
+	return body
+}
+
+// Indent the JSON body.  Bodies that are not JSON are returned as-is.
+func getIndentedJson(body []byte) string {
+	var indented bytes.Buffer
+	if json.Indent(&indented, body, "", "  ") != nil {
+		return string(body)
+	}
+
+	return indented.String()
 }
 
 func prettyPrintJson(body []byte) {
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Create an Azure client against a fake ARM server.  The access token is already set.
+func newTestAzureClient(t *testing.T, handler http.HandlerFunc) *AzureClient {
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+
+	environment := &Environment{
+		aadLoginUrl:   server.URL,
+		apiVersion:    "2017-08-01",
+		armUrl:        server.URL,
+		httpTransport: http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+
+	config := &Config{Credentials: AzureCredentials{SubscriptionID: "sub1", TenantID: "tenant1"}}
+	azureClient := NewAzureClient(config, environment)
+	azureClient.accessToken = "token"
+	return azureClient
+}
+
+func TestTryGetJsonResponseBody(t *testing.T) {
+	tests := []struct {
+		statusCode    int
+		body          string
+		expectedBody  string
+		expectedError string
+	}{
+		{200, `{"value":[]}`, `{"value":[]}`, ""},
+		{404, `{"error":{"code":"ResourceNotFound","message":"The resource was not found."}}`, "", "status code 404: The resource was not found."},
+		{502, `<html>Bad Gateway</html>`, "", "status code 502"},
+		{400, `{"error":{"code":"BadRequest"}}`, "", "status code 400"},
+	}
+
+	for _, test := range tests {
+		azureClient := newTestAzureClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer token" {
+				t.Errorf("unexpected Authorization header %q", r.Header.Get("Authorization"))
+			}
+
+			w.WriteHeader(test.statusCode)
+			w.Write([]byte(test.body))
+		})
+
+		body, err := azureClient.tryGetJsonResponseBody("GET", "/subscriptions/sub1?api-version=2020-01-01", nil)
+		if len(test.expectedError) > 0 {
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("status code %d: expected error %q, got %v", test.statusCode, test.expectedError, err)
+			}
+
+			continue
+		}
+
+		if err != nil || string(body) != test.expectedBody {
+			t.Errorf("status code %d: expected body %q, got %q, %v", test.statusCode, test.expectedBody, body, err)
+		}
+	}
+}
+
+func TestTryGetMetricDefinitions(t *testing.T) {
+	tests := []struct {
+		statusCode    int
+		body          string
+		expectedCount int
+		expectedError string
+	}{
+		{200, `{"value":[{"name":{"value":"Transactions"},"primaryAggregationType":"Total"}]}`, 1, ""},
+		{400, `{"error":{"code":"BadRequest","message":"Metric namespace bad is not available."}}`, 0, "Metric namespace bad is not available."},
+		{500, `not json`, 0, "status code 500"},
+		{200, `not json`, 0, "Error unmarshalling"},
+	}
+
+	for _, test := range tests {
+		azureClient := newTestAzureClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.statusCode)
+			w.Write([]byte(test.body))
+		})
+
+		metricDefinitions, err := azureClient.tryGetMetricDefinitions("/subscriptions/sub1/resourceGroups/rg/providers/Microsoft.Storage/storageAccounts/a", "bad")
+		if len(test.expectedError) > 0 {
+			if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+				t.Errorf("%d %s: expected error containing %q, got %v", test.statusCode, test.body, test.expectedError, err)
+			}
+
+			continue
+		}
+
+		if err != nil || len(metricDefinitions) != test.expectedCount {
+			t.Errorf("%d %s: expected %d metric definitions, got %v, %v", test.statusCode, test.body, test.expectedCount, metricDefinitions, err)
+		}
+	}
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
-	"encoding/json"
+	"bufio"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"strings"
+	"time"
 
 	. "github.com/ahmetb/go-linq"
 	log "github.com/sirupsen/logrus"
@@ -20,6 +22,15 @@ func NewCommandProcessor(config *Config, environment *Environment) *CommandProce
 	}
 }
 
+// Ask the user to confirm on the console.  Only "y" and "yes" are accepted.
+func confirm(prompt string) bool {
+	fmt.Printf("%s [y/N]: ", prompt)
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
 func (processor *CommandProcessor) processGetCommand(getUrl string) {
 	response := processor.azureClient.sendHttpMessage("GET", getUrl)
 
@@ -32,9 +43,25 @@ func (processor *CommandProcessor) processGetCommand(getUrl string) {
 	prettyPrintJson(body)
 }
 
-func (processor *CommandProcessor) processSummarizeCommand(maxContinuation int) {
+func (processor *CommandProcessor) processSummarizeCommand(maxContinuation int, filter ArmResourceFilter, outputFormat string, isSummaryEnabled bool) {
 	// Invoke Azure Resource Manager resource cache API to find all Azure resources on the subscription
-	armResources := processor.azureClient.getAzureResources(maxContinuation)
+	armResources := processor.getSelectedAzureResources(maxContinuation, filter)
+
+	if isSummaryEnabled {
+		summaryGroups := summarizeArmResources(armResources)
+		if outputFormat == TextOutputFormat {
+			printArmResourceSummary(summaryGroups)
+		} else {
+			printOutput(outputFormat, getArmResourceSummaryOutputTable(summaryGroups), summaryGroups)
+		}
+
+		return
+	}
+
+	if outputFormat != TextOutputFormat {
+		printOutput(outputFormat, getArmResourcesOutputTable(armResources), armResources)
+		return
+	}
 
 	// Format the console output to group by {Location}, {ResourceType}, {Id}
 	armResourceMap := make(map[string]map[string]map[string]ArmResource)
@@ -87,18 +114,44 @@ func (processor *CommandProcessor) processSummarizeCommand(maxContinuation int)
 	}
 }
 
-func (processor *CommandProcessor) processGrafanaCommand(titlePrefix string, dataSourceName string, maxContinuation int, maxDashboardResources int, resourceType string, resourceKind string, subResourceType string, subResourceName string) {
-	// Invoke Azure Resource Manager resource cache API to find all Azure resources on the subscription
-	armResources := processor.azureClient.getAzureResources(maxContinuation)
-
-	encodedResourceType := resourceType
-	if len(subResourceType) > 0 {
-		encodedResourceType += "/" + subResourceType
+// Save the Azure resources on the subscription to a snapshot file
+func (processor *CommandProcessor) processSnapshotCommand(maxContinuation int, filter ArmResourceFilter, snapshotFile string) {
+	snapshot := ArmResourceSnapshot{
+		Timestamp:      time.Now().UTC(),
+		SubscriptionId: processor.azureClient.config.Credentials.SubscriptionID,
+		Resources:      processor.getSelectedAzureResources(maxContinuation, filter),
 	}
 
-	if len(resourceKind) > 0 {
-		encodedResourceType += "/kind/" + resourceKind
+	snapshot.save(snapshotFile)
+	fmt.Printf("Saved %d resources to %s\n", len(snapshot.Resources), snapshotFile)
+}
+
+// Append the Azure resources on the subscription to the SQLite database as a new snapshot
+func (processor *CommandProcessor) processSqliteExportCommand(maxContinuation int, filter ArmResourceFilter, databaseFile string) {
+	armSubscription := processor.azureClient.getSubscription()
+	armResources := processor.getSelectedAzureResources(maxContinuation, filter)
+
+	snapshotId := exportToSqlite(databaseFile, time.Now().UTC(), armSubscription, armResources)
+	fmt.Printf("Exported %d resources to %s as snapshot %d\n", len(armResources), databaseFile, snapshotId)
+}
+
+// Show the added, removed and changed resources between two snapshot files
+func processDiffCommand(beforeSnapshotFile string, afterSnapshotFile string, outputFormat string) {
+	before := loadArmResourceSnapshot(beforeSnapshotFile)
+	after := loadArmResourceSnapshot(afterSnapshotFile)
+	changes := diffArmResourceSnapshots(before, after)
+
+	if outputFormat == TextOutputFormat {
+		printArmResourceChanges(before, after, changes)
+	} else {
+		printOutput(outputFormat, getArmResourceChangesOutputTable(changes), changes)
 	}
+}
+
+// Find the Azure resources of the given resource type and kind
+func (processor *CommandProcessor) getFilteredAzureResources(maxContinuation int, resourceBackend string, resourceType string, resourceKind string) []ArmResource {
+	// Invoke Azure Resource Manager resource cache API (or Azure Resource Graph) to find all Azure resources on the subscription
+	armResources := processor.getAzureResources(maxContinuation, resourceBackend, resourceType, resourceKind)
 
 	// Filter by resource type and resoure kind
 	// TODO, move into GET API as server side filter
@@ -111,62 +164,180 @@ func (processor *CommandProcessor) processGrafanaCommand(titlePrefix string, dat
 		}
 	}).ToSlice(&filteredArmResources)
 
-	armResources = filteredArmResources
+	return filteredArmResources
+}
 
-	// Group by {Location}
-	armResourceMap := make(map[string]map[string]ArmResource)
-	for _, armResource := range armResources {
-		if len(armResource.Location) == 0 {
-			continue
-		}
+func (processor *CommandProcessor) processGrafanaCommand(templateSource GrafanaTemplateSource, options GrafanaGenerateOptions, maxContinuation int, resourceType string, resourceKind string, subResourceType string, subResourceName string, resourceBackend string) {
+	encodedResourceType := resourceType
+	if len(subResourceType) > 0 {
+		encodedResourceType += "/" + subResourceType
+	}
 
-		armResourceByIdMap, ok := armResourceMap[armResource.Location]
-		if !ok {
-			armResourceByIdMap = make(map[string]ArmResource)
-			armResourceMap[armResource.Location] = armResourceByIdMap
-		}
+	if len(resourceKind) > 0 {
+		encodedResourceType += "/kind/" + resourceKind
+	}
 
-		armResourceByIdMap[armResource.Id] = armResource
+	writer := NewGrafanaDashboardWriter(options, processor.azureClient.config.Credentials)
+	if options.IsVariablesEnabled {
+		processor.processGrafanaVariablesCommand(templateSource, writer, options, encodedResourceType, resourceType, resourceKind, subResourceType, subResourceName)
+		writer.close()
+		return
+	}
+
+	armResources := processor.getFilteredAzureResources(maxContinuation, resourceBackend, resourceType, resourceKind)
+
+	dashboardGroups, err := getGrafanaDashboardGroups(armResources, options.GroupBy)
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	// Read Grafana JSON template for resource type
-	dashboardTemplates := getGitHubGrafanaTemplates(resourceType, resourceKind, subResourceType)
-	if len(dashboardTemplates) == 0 {
-		fmt.Println("No dashboards found for resource type on github")
+	dashboardTemplates := getGrafanaTemplates(templateSource, resourceType, subResourceType)
+
+	// Check every template before writing any dashboard
+	for _, dashboardTemplate := range dashboardTemplates {
+		err := NewGrafanaDashboard(dashboardTemplate.Contents).checkLayout(options.Layout)
+		if err != nil {
+			log.Fatalf("Template %s: %v", dashboardTemplate.Name, err)
+		}
 	}
 
+	pagedDashboards := make([]GrafanaPagedDashboard, 0)
 	for _, dashboardTemplate := range dashboardTemplates {
-		distinctRegions := getDistinctRegions(armResources)
-		distinctRegions = append(distinctRegions, "allregions")
-
-		// Generate Grafana dashboard JSONs - one dashboard for each region and one dashboard for all regions
-		for _, region := range distinctRegions {
-			var dashboardArmResources []ArmResource
-			if strings.EqualFold(region, "allregions") {
-				dashboardArmResources = armResources
-			} else {
-				From(armResources).WhereT(func(r ArmResource) bool {
-					return strings.EqualFold(r.Location, region)
-				}).ToSlice(&dashboardArmResources)
+		// Generate Grafana dashboard JSONs - one dashboard for each group, e.g. for each region and for all regions.
+		// Dashboards with more than maxDashboardResources resources are split into pages.
+		for _, dashboardGroup := range dashboardGroups {
+			group := dashboardGroup.Name
+			title := fmt.Sprintf("%s - %s - %s - %s", options.TitlePrefix, encodedResourceType, dashboardTemplate.Name, group)
+
+			pages := getArmResourcePages(dashboardGroup.ArmResources, options.MaxDashboardResources)
+			pagedDashboard := GrafanaPagedDashboard{
+				Title:     title,
+				Tag:       getGrafanaDashboardTag(options.TitlePrefix, encodedResourceType, dashboardTemplate.Name, group),
+				PageCount: len(pages),
 			}
 
-			dashboard := NewGrafanaDashboard(dashboardTemplate.Contents)
-			title := fmt.Sprintf("%s - %s - %s - %s", titlePrefix, encodedResourceType, dashboardTemplate.Name, region)
-			dashboard.update(title, dataSourceName, maxDashboardResources, dashboardArmResources, subResourceName)
+			if len(pages) > 1 {
+				pagedDashboards = append(pagedDashboards, pagedDashboard)
+			}
 
-			generatedDashboard, err := json.MarshalIndent(dashboard.ParsedJson, "", " ")
-			if err != nil {
-				log.Fatalf("Error generating dashboard: %v", err)
+			for pageIndex, pageArmResources := range pages {
+				// Single page dashboards share the uid of the first page, so they are updated when split into pages
+				dashboard := NewGrafanaDashboard(dashboardTemplate.Contents)
+				dashboard.setUid(options.TitlePrefix, encodedResourceType, dashboardTemplate.Name, group, fmt.Sprint(pageIndex+1))
+				if len(pages) > 1 {
+					dashboard.update(fmt.Sprintf("%s - page %d of %d", title, pageIndex+1, len(pages)), options.DataSourceName, options.Layout, pageArmResources, subResourceType, subResourceName)
+					dashboard.addPageLinks(pagedDashboard)
+					writer.write(dashboard, newGrafanaDashboardFile(encodedResourceType, group, options.TitlePrefix, encodedResourceType, dashboardTemplate.Name, group, "page", fmt.Sprint(pageIndex+1)))
+				} else {
+					dashboard.update(title, options.DataSourceName, options.Layout, pageArmResources, subResourceType, subResourceName)
+					writer.write(dashboard, newGrafanaDashboardFile(encodedResourceType, group, options.TitlePrefix, encodedResourceType, dashboardTemplate.Name, group))
+				}
 			}
+		}
+	}
+
+	if len(pagedDashboards) > 0 {
+		indexTitle := fmt.Sprintf("%s - %s - index", options.TitlePrefix, encodedResourceType)
+		indexDashboard := NewGrafanaIndexDashboard(indexTitle, pagedDashboards)
+		indexDashboard.setUid(options.TitlePrefix, encodedResourceType, "index")
+		writer.write(indexDashboard, newGrafanaDashboardFile(encodedResourceType, "", options.TitlePrefix, encodedResourceType, "index"))
+	}
 
-			outputFile := strings.ToLower("dashboard_" + titlePrefix + "_" + encodedResourceType + "_" + dashboardTemplate.Name + "_" + region)
-			outputFile = strings.Replace(outputFile, " ", "_", -1)
-			outputFile = strings.Replace(outputFile, "/", "_", -1)
-			outputFile = strings.Replace(outputFile, ".", "_", -1)
-			outputFile += ".json"
-			ioutil.WriteFile(outputFile, generatedDashboard, 0644)
+	writer.close()
+}
 
-			fmt.Printf("Created %s\n", outputFile)
+// Read the Grafana dashboard templates of the resource type from the template source
+func getGrafanaTemplates(templateSource GrafanaTemplateSource, resourceType string, subResourceType string) []GrafanaDashboardTemplate {
+	dashboardTemplates, err := templateSource.getTemplates(getGrafanaTemplateDir(resourceType, subResourceType))
+	if err != nil {
+		log.Fatalf("Error reading dashboard templates from %s: %v", templateSource, err)
+	}
+
+	// Warn on stderr, as the validate command may print json or csv to stdout
+	if len(dashboardTemplates) == 0 {
+		if len(subResourceType) > 0 {
+			resourceType += "/" + subResourceType
 		}
+
+		log.Warnf("No dashboards found for resource type %s in %s", resourceType, templateSource)
 	}
+
+	return dashboardTemplates
+}
+
+// Generate one dashboard for each template that selects the resource with template variables rather than listing the
+// resources, so the Azure resources are not queried
+func (processor *CommandProcessor) processGrafanaVariablesCommand(templateSource GrafanaTemplateSource, writer GrafanaDashboardWriter, options GrafanaGenerateOptions, encodedResourceType string, resourceType string, resourceKind string, subResourceType string, subResourceName string) {
+	dashboardTemplates := getGrafanaTemplates(templateSource, resourceType, subResourceType)
+
+	for _, dashboardTemplate := range dashboardTemplates {
+		dashboard := NewGrafanaDashboard(dashboardTemplate.Contents)
+		dashboard.setUid(options.TitlePrefix, encodedResourceType, dashboardTemplate.Name)
+		title := fmt.Sprintf("%s - %s - %s", options.TitlePrefix, encodedResourceType, dashboardTemplate.Name)
+		dashboard.updateWithVariables(title, options.DataSourceName, processor.azureClient.config.Credentials.SubscriptionID, resourceType, subResourceType, subResourceName)
+		writer.write(dashboard, newGrafanaDashboardFile(encodedResourceType, "", options.TitlePrefix, encodedResourceType, dashboardTemplate.Name))
+	}
+}
+
+// Validate the Grafana dashboard templates of the resource type against the metric definitions of the Azure resources
+func (processor *CommandProcessor) processGrafanaValidateCommand(templateSource GrafanaTemplateSource, maxContinuation int, maxResources int, resourceType string, resourceKind string, subResourceType string, subResourceName string, resourceBackend string, outputFormat string) {
+	armResources := processor.getFilteredAzureResources(maxContinuation, resourceBackend, resourceType, resourceKind)
+	if len(armResources) == 0 {
+		log.Fatalf("No Azure resources found for resource type %s", resourceType)
+	}
+
+	if maxResources < len(armResources) {
+		armResources = armResources[:maxResources]
+	}
+
+	dashboardTemplates := getGrafanaTemplates(templateSource, resourceType, subResourceType)
+
+	validator := NewGrafanaTemplateValidator(processor.azureClient)
+	mismatches := make([]GrafanaTargetMismatch, 0)
+	for _, dashboardTemplate := range dashboardTemplates {
+		dashboard := NewGrafanaDashboard(dashboardTemplate.Contents)
+		for _, armResource := range armResources {
+			// Sub-resources such as blobServices expose their own metric definitions
+			resourceId := getMetricsResourceId(armResource, subResourceType, subResourceName)
+			mismatches = append(mismatches, validator.validate(dashboardTemplate.Name, dashboard, resourceId)...)
+		}
+	}
+
+	if outputFormat == TextOutputFormat {
+		printGrafanaTargetMismatches(mismatches)
+	} else {
+		printOutput(outputFormat, getGrafanaTargetMismatchesOutputTable(mismatches), mismatches)
+	}
+
+	if len(mismatches) > 0 {
+		os.Exit(1)
+	}
+}
+
+func printGrafanaTargetMismatches(mismatches []GrafanaTargetMismatch) {
+	if len(mismatches) == 0 {
+		fmt.Println("All dashboard templates are valid")
+		return
+	}
+
+	// Group by {Template}, {ResourceId}
+	template := ""
+	resourceId := ""
+	for _, mismatch := range mismatches {
+		if mismatch.Template != template {
+			template = mismatch.Template
+			resourceId = ""
+			fmt.Printf("Template: %s:\n", template)
+		}
+
+		if mismatch.ResourceId != resourceId {
+			resourceId = mismatch.ResourceId
+			fmt.Printf("  Id: %s:\n", resourceId)
+		}
+
+		fmt.Printf("    Panel '%s', metric '%s': %s\n", mismatch.Panel, mismatch.MetricName, mismatch.Problem)
+	}
+
+	fmt.Printf("\n%d mismatches found\n", len(mismatches))
 }
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestGetGrafanaTemplatesWarnsOnStderr(t *testing.T) {
+	defer initLogging(false)
+	initLogging(false)
+
+	var logOutput bytes.Buffer
+	log.SetOutput(&logOutput)
+
+	stdout := os.Stdout
+	reader, writer, _ := os.Pipe()
+	os.Stdout = writer
+	dashboardTemplates := getGrafanaTemplates(&LocalGrafanaTemplateSource{rootDir: t.TempDir()}, "Microsoft.Storage/storageAccounts", "blobServices")
+	writer.Close()
+	os.Stdout = stdout
+
+	output, _ := ioutil.ReadAll(reader)
+	if len(dashboardTemplates) != 0 || len(output) > 0 {
+		t.Errorf("expected no templates and no output, got %v and %q", dashboardTemplates, output)
+	}
+
+	if !strings.Contains(logOutput.String(), "No dashboards found for resource type Microsoft.Storage/storageAccounts/blobServices in") {
+		t.Errorf("expected warning, got %s", logOutput.String())
+	}
+}
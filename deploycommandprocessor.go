@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ansiColorReset  = "\033[0m"
+	ansiColorRed    = "\033[31m"
+	ansiColorGreen  = "\033[32m"
+	ansiColorYellow = "\033[33m"
+	ansiColorPurple = "\033[35m"
+	ansiColorGray   = "\033[90m"
+)
+
+// Symbol and color for each what-if change type, following the Azure CLI what-if output
+var whatIfChangeTypeFormats = map[string][2]string{
+	"create":      {"+", ansiColorGreen},
+	"delete":      {"-", ansiColorRed},
+	"modify":      {"~", ansiColorPurple},
+	"deploy":      {"!", ansiColorYellow},
+	"array":       {"~", ansiColorPurple},
+	"nochange":    {"=", ansiColorGray},
+	"noeffect":    {"x", ansiColorGray},
+	"ignore":      {"*", ansiColorGray},
+	"unsupported": {"?", ansiColorGray},
+}
+
+func (processor *CommandProcessor) processDeployWhatIfCommand(scope DeploymentScope, deploymentName string, request DeploymentRequest, isColorEnabled bool) {
+	result := processor.azureClient.whatIfDeployment(scope, deploymentName, request)
+	printWhatIfResult(result, isColorEnabled)
+
+	if result.Error != nil {
+		os.Exit(1)
+	}
+}
+
+// Submit the deployment and wait for it to complete.  With preview, the what-if changes are printed first and the
+// deployment is only submitted after confirmation.
+func (processor *CommandProcessor) processDeployCreateCommand(scope DeploymentScope, deploymentName string, request DeploymentRequest, isPreviewEnabled bool, isConfirmed bool, isColorEnabled bool, pollInterval time.Duration) {
+	if isPreviewEnabled {
+		result := processor.azureClient.whatIfDeployment(scope, deploymentName, request)
+		printWhatIfResult(result, isColorEnabled)
+
+		if result.Error != nil {
+			os.Exit(1)
+		}
+
+		if !isConfirmed && !confirm("Do you want to continue with the deployment?") {
+			fmt.Println("Deployment canceled")
+			return
+		}
+	}
+
+	deployment := processor.azureClient.createDeployment(scope, deploymentName, request)
+	fmt.Printf("Deployment %s: %s\n", deployment.Name, deployment.Properties.ProvisioningState)
+
+	for !deployment.isTerminal() {
+		time.Sleep(pollInterval)
+
+		provisioningState := deployment.Properties.ProvisioningState
+		deployment = processor.azureClient.getDeployment(scope, deploymentName)
+		if !strings.EqualFold(provisioningState, deployment.Properties.ProvisioningState) {
+			fmt.Printf("Deployment %s: %s\n", deployment.Name, deployment.Properties.ProvisioningState)
+		}
+	}
+
+	if !strings.EqualFold(deployment.Properties.ProvisioningState, "succeeded") {
+		if deployment.Properties.Error != nil {
+			fmt.Printf("\nError: %s\n", deployment.Properties.Error.String())
+		}
+
+		printFailedDeploymentOperations(processor.azureClient.getDeploymentOperations(scope, deploymentName))
+		os.Exit(1)
+	}
+
+	if len(deployment.Properties.Outputs) > 0 {
+		fmt.Println("\nOutputs:")
+		printJson(deployment.Properties.Outputs)
+	}
+}
+
+func printFailedDeploymentOperations(operations []DeploymentOperation) {
+	for _, operation := range operations {
+		if !strings.EqualFold(operation.Properties.ProvisioningState, "failed") {
+			continue
+		}
+
+		targetResource := operation.Properties.TargetResource
+		fmt.Printf("\nFailed operation %s on %s (%s) - status code: %s\n", operation.OperationId, targetResource.Id, targetResource.ResourceType, operation.Properties.StatusCode)
+		if operation.Properties.StatusMessage.Error != nil {
+			fmt.Printf("  %s\n", strings.Replace(operation.Properties.StatusMessage.Error.String(), "\n", "\n  ", -1))
+		}
+	}
+}
+
+func printWhatIfResult(result WhatIfOperationResult, isColorEnabled bool) {
+	if result.Error != nil {
+		fmt.Printf("What-if failed: %s\n", result.Error.String())
+		return
+	}
+
+	changeCounts := make(map[string]int)
+	for _, change := range result.Properties.Changes {
+		changeCounts[change.ChangeType]++
+
+		fmt.Println(formatWhatIfLine(change.ChangeType, change.ResourceId, isColorEnabled))
+		printWhatIfPropertyChanges(change.Delta, "    ", isColorEnabled)
+
+		// Show the full resource for creates and deletes since there is no delta
+		if strings.EqualFold(change.ChangeType, "create") && change.After != nil {
+			printWhatIfProperties(change.ChangeType, change.After, isColorEnabled)
+		} else if strings.EqualFold(change.ChangeType, "delete") && change.Before != nil {
+			printWhatIfProperties(change.ChangeType, change.Before, isColorEnabled)
+		}
+	}
+
+	changeTypes := make([]string, 0)
+	for changeType := range changeCounts {
+		changeTypes = append(changeTypes, changeType)
+	}
+
+	sort.Strings(changeTypes)
+
+	summary := make([]string, 0)
+	for _, changeType := range changeTypes {
+		summary = append(summary, fmt.Sprintf("%d to %s", changeCounts[changeType], strings.ToLower(changeType)))
+	}
+
+	fmt.Printf("\nResource changes: %s\n", strings.Join(summary, ", "))
+}
+
+func printWhatIfPropertyChanges(propertyChanges []WhatIfPropertyChange, indent string, isColorEnabled bool) {
+	for _, propertyChange := range propertyChanges {
+		line := indent + propertyChange.Path
+		switch strings.ToLower(propertyChange.PropertyChangeType) {
+		case "create":
+			line += ": " + formatWhatIfValue(propertyChange.After)
+		case "delete":
+			line += ": " + formatWhatIfValue(propertyChange.Before)
+		case "modify":
+			line += ": " + formatWhatIfValue(propertyChange.Before) + " => " + formatWhatIfValue(propertyChange.After)
+		case "array":
+			line += ":"
+		}
+
+		fmt.Println(formatWhatIfLine(propertyChange.PropertyChangeType, line, isColorEnabled))
+		printWhatIfPropertyChanges(propertyChange.Children, indent+"  ", isColorEnabled)
+	}
+}
+
+func printWhatIfProperties(changeType string, properties map[string]interface{}, isColorEnabled bool) {
+	propertiesJson, err := json.MarshalIndent(properties, "", "  ")
+	if err != nil {
+		log.Fatalf("Error generating what-if output: %v", err)
+	}
+
+	for _, line := range strings.Split(string(propertiesJson), "\n") {
+		fmt.Println(formatWhatIfLine(changeType, "    "+line, isColorEnabled))
+	}
+}
+
+func formatWhatIfLine(changeType string, text string, isColorEnabled bool) string {
+	format, ok := whatIfChangeTypeFormats[strings.ToLower(changeType)]
+	if !ok {
+		format = [2]string{" ", ""}
+	}
+
+	line := fmt.Sprintf("  %s %s", format[0], text)
+	if isColorEnabled && len(format[1]) > 0 {
+		line = format[1] + line + ansiColorReset
+	}
+
+	return line
+}
+
+func formatWhatIfValue(value interface{}) string {
+	valueJson, err := json.Marshal(value)
+	if err != nil {
+		log.Fatalf("Error generating what-if output: %v", err)
+	}
+
+	return string(valueJson)
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DeploymentApiVersion = "2021-04-01"
+)
+
+// The scope a template is deployed to.  The management group takes precedence over the resource group; without either
+// the template is deployed to the subscription.
+type DeploymentScope struct {
+	ManagementGroup string
+	ResourceGroup   string
+}
+
+type DeploymentRequest struct {
+	Location   string               `json:"location,omitempty"`
+	Properties DeploymentProperties `json:"properties"`
+}
+
+type DeploymentProperties struct {
+	Template   json.RawMessage `json:"template"`
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+	Mode       string          `json:"mode"`
+}
+
+type Deployment struct {
+	Id         string                     `json:"id"`
+	Name       string                     `json:"name"`
+	Properties DeploymentResultProperties `json:"properties"`
+}
+
+type DeploymentResultProperties struct {
+	ProvisioningState string                 `json:"provisioningState"`
+	CorrelationId     string                 `json:"correlationId"`
+	Timestamp         string                 `json:"timestamp"`
+	Duration          string                 `json:"duration"`
+	Outputs           map[string]interface{} `json:"outputs"`
+	Error             *ArmError              `json:"error"`
+}
+
+type ArmError struct {
+	Code    string     `json:"code"`
+	Message string     `json:"message"`
+	Target  string     `json:"target"`
+	Details []ArmError `json:"details"`
+}
+
+type DeploymentOperation struct {
+	OperationId string                        `json:"operationId"`
+	Properties  DeploymentOperationProperties `json:"properties"`
+}
+
+type DeploymentOperationProperties struct {
+	ProvisioningState string                   `json:"provisioningState"`
+	Timestamp         string                   `json:"timestamp"`
+	StatusCode        string                   `json:"statusCode"`
+	StatusMessage     DeploymentStatusMessage  `json:"statusMessage"`
+	TargetResource    DeploymentTargetResource `json:"targetResource"`
+}
+
+type DeploymentStatusMessage struct {
+	Error *ArmError `json:"error"`
+}
+
+type DeploymentTargetResource struct {
+	Id           string `json:"id"`
+	ResourceType string `json:"resourceType"`
+	ResourceName string `json:"resourceName"`
+}
+
+type WhatIfOperationResult struct {
+	Status     string                 `json:"status"`
+	Properties WhatIfResultProperties `json:"properties"`
+	Error      *ArmError              `json:"error"`
+}
+
+type WhatIfResultProperties struct {
+	Changes []WhatIfChange `json:"changes"`
+}
+
+type WhatIfChange struct {
+	ResourceId string                 `json:"resourceId"`
+	ChangeType string                 `json:"changeType"`
+	Before     map[string]interface{} `json:"before"`
+	After      map[string]interface{} `json:"after"`
+	Delta      []WhatIfPropertyChange `json:"delta"`
+}
+
+type WhatIfPropertyChange struct {
+	Path               string                 `json:"path"`
+	PropertyChangeType string                 `json:"propertyChangeType"`
+	Before             interface{}            `json:"before"`
+	After              interface{}            `json:"after"`
+	Children           []WhatIfPropertyChange `json:"children"`
+}
+
+// Read the template and the optional parameters file into the deployment request.  Parameter files in the
+// deploymentParameters schema are unwrapped to their "parameters" property.
+func newDeploymentRequest(templateFile string, parametersFile string, location string, mode string) DeploymentRequest {
+	template, err := ioutil.ReadFile(templateFile)
+	if err != nil {
+		log.Fatalf("Error reading template file: %v", err)
+	}
+
+	request := DeploymentRequest{
+		Location: location,
+		Properties: DeploymentProperties{
+			Template: json.RawMessage(template),
+			Mode:     mode,
+		},
+	}
+
+	if len(parametersFile) > 0 {
+		parameters, err := ioutil.ReadFile(parametersFile)
+		if err != nil {
+			log.Fatalf("Error reading parameters file: %v", err)
+		}
+
+		var parametersFileJson map[string]json.RawMessage
+		err = json.Unmarshal(parameters, &parametersFileJson)
+		if err != nil {
+			log.Fatalf("Error parsing parameters file: %v", err)
+		}
+
+		if wrappedParameters, ok := parametersFileJson["parameters"]; ok {
+			parameters = wrappedParameters
+		}
+
+		request.Properties.Parameters = json.RawMessage(parameters)
+	}
+
+	return request
+}
+
+func (azureClient *AzureClient) getDeploymentUrl(scope DeploymentScope, deploymentName string) string {
+	if len(scope.ManagementGroup) > 0 {
+		return fmt.Sprintf("/providers/Microsoft.Management/managementGroups/%s/providers/Microsoft.Resources/deployments/%s", scope.ManagementGroup, deploymentName)
+	}
+
+	if len(scope.ResourceGroup) > 0 {
+		return fmt.Sprintf(
+			"/subscriptions/%s/resourcegroups/%s/providers/Microsoft.Resources/deployments/%s",
+			azureClient.config.Credentials.SubscriptionID,
+			scope.ResourceGroup,
+			deploymentName,
+		)
+	}
+
+	return fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Resources/deployments/%s", azureClient.config.Credentials.SubscriptionID, deploymentName)
+}
+
+func (azureClient *AzureClient) createDeployment(scope DeploymentScope, deploymentName string, request DeploymentRequest) Deployment {
+	targetUrl := fmt.Sprintf("%s?api-version=%s", azureClient.getDeploymentUrl(scope, deploymentName), DeploymentApiVersion)
+
+	response := azureClient.sendJsonHttpMessage("PUT", targetUrl, request)
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusCreated {
+		log.Fatalf("Error creating deployment - status code: %d", response.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		log.Fatalf("Error reading body of response: %v", err)
+	}
+
+	return convertToDeployment(body)
+}
+
+func (azureClient *AzureClient) getDeployment(scope DeploymentScope, deploymentName string) Deployment {
+	targetUrl := fmt.Sprintf("%s?api-version=%s", azureClient.getDeploymentUrl(scope, deploymentName), DeploymentApiVersion)
+	return convertToDeployment(azureClient.getResponseBody("GET", targetUrl))
+}
+
+func (azureClient *AzureClient) getDeploymentOperations(scope DeploymentScope, deploymentName string) []DeploymentOperation {
+	targetUrl := fmt.Sprintf("%s/operations?api-version=%s", azureClient.getDeploymentUrl(scope, deploymentName), DeploymentApiVersion)
+
+	operations := make([]DeploymentOperation, 0)
+	for _, value := range azureClient.getPagedValues(targetUrl, 10) {
+		var operation DeploymentOperation
+		err := json.Unmarshal(value, &operation)
+		if err != nil {
+			log.Fatalf("Error unmarshalling deployment operation response body: %v", err)
+		}
+
+		operations = append(operations, operation)
+	}
+
+	return operations
+}
+
+// Run the what-if operation and wait for the predicted changes
+func (azureClient *AzureClient) whatIfDeployment(scope DeploymentScope, deploymentName string, request DeploymentRequest) WhatIfOperationResult {
+	targetUrl := fmt.Sprintf("%s/whatIf?api-version=%s", azureClient.getDeploymentUrl(scope, deploymentName), DeploymentApiVersion)
+
+	response := azureClient.sendJsonHttpMessage("POST", targetUrl, request)
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusAccepted {
+		log.Fatalf("Error running what-if - status code: %d", response.StatusCode)
+	}
+
+	var result WhatIfOperationResult
+	err := json.Unmarshal(azureClient.waitForAsyncOperation(response), &result)
+	if err != nil {
+		log.Fatalf("Error unmarshalling what-if response body: %v", err)
+	}
+
+	return result
+}
+
+func (deployment *Deployment) isTerminal() bool {
+	switch strings.ToLower(deployment.Properties.ProvisioningState) {
+	case "succeeded", "failed", "canceled":
+		return true
+	}
+
+	return false
+}
+
+func (armError *ArmError) String() string {
+	message := fmt.Sprintf("%s: %s", armError.Code, armError.Message)
+	for _, detail := range armError.Details {
+		message += "\n  " + strings.Replace(detail.String(), "\n", "\n  ", -1)
+	}
+
+	return message
+}
+
+func convertToDeployment(body []byte) Deployment {
+	var deployment Deployment
+	err := json.Unmarshal(body, &deployment)
+	if err != nil {
+		log.Fatalf("Error unmarshalling deployment response body: %v", err)
+	}
+
+	return deployment
+}
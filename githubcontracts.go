@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -17,118 +18,234 @@ type GitHubContentItem struct {
 	Url         string `json:"url"`
 }
 
-type GitHubDashboardTemplate struct {
-	Name     string
-	Contents string
+// Reads the templates of a GitHub repository through the contents API.  Private repositories need a token in the
+// GITHUB_TOKEN environment variable.
+type GitHubGrafanaTemplateSource struct {
+	owner      string
+	repo       string
+	ref        string
+	token      string
+	apiRootUrl string
+	httpClient *http.Client
+
+	// The commit of the ref, resolved once per run so every template is read from the same commit
+	commitSha string
 }
 
 const (
-	GitHubGrafanaTemplateRootUrl = "https://api.github.com/repos/asheniam/azure-grafana-dashboard-templates/contents/"
-)
-
-func getGitHubGrafanaTemplates(resourceType string, resourceKind string, subResourceType string) []GitHubDashboardTemplate {
-
-	var httpClient *http.Client = &http.Client{}
+	GitHubApiRootUrl = "https://api.github.com"
 
-	// Generate the root URL for the given ARM resource type.  This is the folder that contains the Grafana dashboard templates
-	// for this ARM resource type.
-	encodedResourceType := resourceType
+	DefaultGitHubGrafanaTemplateOwner = "asheniam"
+	DefaultGitHubGrafanaTemplateRepo  = "azure-grafana-dashboard-templates"
+	DefaultGitHubGrafanaTemplateRef   = "master"
+)
 
-	if len(subResourceType) > 0 {
-		encodedResourceType = resourceType + "/" + subResourceType
+func NewGitHubGrafanaTemplateSource(owner string, repo string, ref string) *GitHubGrafanaTemplateSource {
+	return &GitHubGrafanaTemplateSource{
+		owner:      owner,
+		repo:       repo,
+		ref:        ref,
+		token:      os.Getenv("GITHUB_TOKEN"),
+		apiRootUrl: GitHubApiRootUrl,
+		httpClient: &http.Client{},
 	}
+}
 
-	if len(resourceKind) > 0 {
-		resourceType = encodedResourceType + "/kind/" + resourceKind
+func (source *GitHubGrafanaTemplateSource) String() string {
+	return fmt.Sprintf("github:%s/%s@%s", source.owner, source.repo, source.ref)
+}
+
+func (source *GitHubGrafanaTemplateSource) getTemplates(templateDir string) ([]GrafanaDashboardTemplate, error) {
+	ref := source.ref
+	if len(source.commitSha) > 0 {
+		ref = source.commitSha
 	}
 
-	encodedResourceType = strings.Replace(encodedResourceType, ".", "-", -1)
-	encodedResourceType = strings.Replace(encodedResourceType, "/", "-", -1)
-	githubUrl := fmt.Sprintf("%s%s?ref=master", GitHubGrafanaTemplateRootUrl, encodedResourceType)
+	// The folder that contains the Grafana dashboard templates for the ARM resource type
+	githubUrl := fmt.Sprintf("%s/repos/%s/%s/contents/%s?ref=%s", source.apiRootUrl, source.owner, source.repo, templateDir, ref)
 
 	// Get all the Grafana dashboard template subfolders
-	githubContentItems := httpGetGitHubContentItems(httpClient, githubUrl)
+	githubContentItems, err := source.httpGetGitHubContentItems(githubUrl)
+	if err != nil {
+		return nil, err
+	}
 
-	gitHubDashboardTemplates := make([]GitHubDashboardTemplate, 0)
+	dashboardTemplates := make([]GrafanaDashboardTemplate, 0)
 
 	// For each sub-folder, find the template.json
 	for _, githubContentItem := range githubContentItems {
 		if strings.EqualFold(githubContentItem.Type, "dir") &&
 			len(githubContentItem.Url) > 0 {
-			githubContentDashboardFolderItems := httpGetGitHubContentItems(httpClient, githubContentItem.Url)
+			err := validateGrafanaTemplateName(githubContentItem.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			githubContentDashboardFolderItems, err := source.httpGetGitHubContentItems(githubContentItem.Url)
+			if err != nil {
+				return nil, err
+			}
+
 			for _, githubContentDashboardFolderItem := range githubContentDashboardFolderItems {
-				if strings.EqualFold(githubContentDashboardFolderItem.Name, "template.json") {
+				if strings.EqualFold(githubContentDashboardFolderItem.Name, GrafanaTemplateFileName) {
 
 					// Read the template.json
-					templateJson := httpGetGitHubDashboardTemplateJson(httpClient, githubContentDashboardFolderItem.DownloadUrl)
-					dashboardTemplate := GitHubDashboardTemplate{
+					templateJson, err := source.httpGetGitHubDashboardTemplateJson(githubContentDashboardFolderItem.DownloadUrl)
+					if err != nil {
+						return nil, err
+					}
+
+					dashboardTemplate := GrafanaDashboardTemplate{
 						Name:     githubContentItem.Name,
 						Contents: templateJson,
 					}
-					gitHubDashboardTemplates = append(gitHubDashboardTemplates, dashboardTemplate)
+					dashboardTemplates = append(dashboardTemplates, dashboardTemplate)
 				}
 			}
 		}
 	}
 
-	return gitHubDashboardTemplates
+	return dashboardTemplates, nil
 }
 
-func httpGetGitHubContentItems(httpClient *http.Client, targetUrl string) []GitHubContentItem {
-	request, err := http.NewRequest("GET", targetUrl, nil)
+// Get the folders of the resource types at the root of the repository.  The ref is resolved first, so the folders
+// are listed at the same commit as the templates.
+func (source *GitHubGrafanaTemplateSource) getTemplateDirs() ([]string, error) {
+	commitSha, err := source.getTemplatesVersion("")
 	if err != nil {
-		log.Fatalf("Error creating HTTP request: %v", err)
+		return nil, err
 	}
 
-	log.Debugf("Executing GET %s\n", targetUrl)
-	response, err := httpClient.Do(request)
+	githubUrl := fmt.Sprintf("%s/repos/%s/%s/contents?ref=%s", source.apiRootUrl, source.owner, source.repo, commitSha)
+	githubContentItems, err := source.httpGetGitHubContentItems(githubUrl)
 	if err != nil {
-		log.Fatalf("Error sending HTTP request: %v", err)
+		return nil, err
 	}
 
-	log.Debugf("Status code: %d\n", response.StatusCode)
-	defer response.Body.Close()
-
-	githubContentResponse := make([]GitHubContentItem, 0)
-	if response.StatusCode == 200 {
-		body, err := ioutil.ReadAll(response.Body)
-		if err != nil {
-			log.Fatalf("Error reading body of response: %v", err)
+	templateDirs := make([]string, 0)
+	for _, githubContentItem := range githubContentItems {
+		if !strings.EqualFold(githubContentItem.Type, "dir") || strings.HasPrefix(githubContentItem.Name, ".") {
+			continue
 		}
 
-		err = json.Unmarshal(body, &githubContentResponse)
+		err := validateGrafanaTemplateName(githubContentItem.Name)
 		if err != nil {
-			log.Fatalf("Error unmarshalling ARM reource response body: %v", err)
+			return nil, err
 		}
+
+		templateDirs = append(templateDirs, githubContentItem.Name)
+	}
+
+	return templateDirs, nil
+}
+
+// The templates of every resource type are versioned by the commit SHA of the ref, which takes a single API call
+func (source *GitHubGrafanaTemplateSource) getTemplatesVersion(templateDir string) (string, error) {
+	if len(source.commitSha) > 0 {
+		return source.commitSha, nil
+	}
+
+	githubUrl := fmt.Sprintf("%s/repos/%s/%s/commits/%s", source.apiRootUrl, source.owner, source.repo, source.ref)
+	request, err := source.newRequest(githubUrl)
+	if err != nil {
+		return "", err
+	}
+
+	request.Header.Set("Accept", "application/vnd.github.sha")
+
+	log.Debugf("Executing GET %s\n", githubUrl)
+	response, err := source.httpClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+
+	log.Debugf("Status code: %d\n", response.StatusCode)
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Unable to resolve ref %s of %s/%s, status code %d: %s", source.ref, source.owner, source.repo, response.StatusCode, strings.TrimSpace(string(body)))
 	}
 
-	return githubContentResponse
+	source.commitSha = strings.TrimSpace(string(body))
+	return source.commitSha, nil
 }
 
-func httpGetGitHubDashboardTemplateJson(httpClient *http.Client, targetUrl string) string {
+func (source *GitHubGrafanaTemplateSource) newRequest(targetUrl string) (*http.Request, error) {
 	request, err := http.NewRequest("GET", targetUrl, nil)
 	if err != nil {
-		log.Fatalf("Error creating HTTP request: %v", err)
+		return nil, err
+	}
+
+	if len(source.token) > 0 {
+		request.Header.Set("Authorization", "token "+source.token)
+	}
+
+	return request, nil
+}
+
+// Get the contents of a folder, or no contents if the folder does not exist
+func (source *GitHubGrafanaTemplateSource) httpGetGitHubContentItems(targetUrl string) ([]GitHubContentItem, error) {
+	body, statusCode, err := source.httpGetGitHubFile(targetUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	githubContentResponse := make([]GitHubContentItem, 0)
+	if statusCode == http.StatusNotFound {
+		return githubContentResponse, nil
+	}
+
+	// Fail rather than caching no templates when rate limited
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s failed with status code %d, set GITHUB_TOKEN to raise the rate limit", targetUrl, statusCode)
+	}
+
+	err = json.Unmarshal(body, &githubContentResponse)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing the contents of %s: %v", targetUrl, err)
+	}
+
+	return githubContentResponse, nil
+}
+
+// Download a template.json.  A failed download is an error so an empty template is never cached.
+func (source *GitHubGrafanaTemplateSource) httpGetGitHubDashboardTemplateJson(targetUrl string) (string, error) {
+	body, statusCode, err := source.httpGetGitHubFile(targetUrl)
+	if err != nil {
+		return "", err
+	}
+
+	if statusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s failed with status code %d", targetUrl, statusCode)
+	}
+
+	return string(body), nil
+}
+
+func (source *GitHubGrafanaTemplateSource) httpGetGitHubFile(targetUrl string) ([]byte, int, error) {
+	request, err := source.newRequest(targetUrl)
+	if err != nil {
+		return nil, 0, err
 	}
 
 	log.Debugf("Executing GET %s\n", targetUrl)
-	response, err := httpClient.Do(request)
+	response, err := source.httpClient.Do(request)
 	if err != nil {
-		log.Fatalf("Error sending HTTP request: %v", err)
+		return nil, 0, err
 	}
 
 	log.Debugf("Status code: %d\n", response.StatusCode)
 	defer response.Body.Close()
 
-	templateJson := ""
-	if response.StatusCode == 200 {
-		body, err := ioutil.ReadAll(response.Body)
-		if err != nil {
-			log.Fatalf("Error reading body of response: %v", err)
-		}
-
-		templateJson = string(body)
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Error reading body of response: %v", err)
 	}
 
-	return templateJson
+	return body, response.StatusCode, nil
 }
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// GitHub API serving a repository with a Microsoft-Web-sites/requests/template.json, whose responses can be failed
+type fakeGitHub struct {
+	mutex          sync.Mutex
+	commitSha      string
+	contentsStatus int
+	downloadStatus int
+	requests       []string
+}
+
+func newFakeGitHub(t *testing.T) (*fakeGitHub, *httptest.Server) {
+	github := &fakeGitHub{commitSha: "sha1", contentsStatus: http.StatusOK, downloadStatus: http.StatusOK}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		github.mutex.Lock()
+		defer github.mutex.Unlock()
+
+		github.requests = append(github.requests, r.URL.RequestURI())
+		writeContents := func(items []GitHubContentItem) {
+			w.WriteHeader(github.contentsStatus)
+			if github.contentsStatus == http.StatusOK {
+				json.NewEncoder(w).Encode(items)
+			}
+		}
+
+		switch r.URL.Path {
+		case "/repos/owner/repo/commits/master":
+			w.Write([]byte(github.commitSha))
+		case "/repos/owner/repo/contents":
+			writeContents([]GitHubContentItem{{Name: "Microsoft-Web-sites", Type: "dir"}, {Name: ".github", Type: "dir"}, {Name: "README.md", Type: "file"}})
+		case "/repos/owner/repo/contents/Microsoft-Web-sites":
+			writeContents([]GitHubContentItem{{Name: "requests", Type: "dir", Url: server.URL + "/repos/owner/repo/contents/Microsoft-Web-sites/requests"}})
+		case "/repos/owner/repo/contents/Microsoft-Web-sites/requests":
+			writeContents([]GitHubContentItem{{Name: "template.json", Type: "file", DownloadUrl: server.URL + "/raw/Microsoft-Web-sites/requests/template.json"}})
+		case "/raw/Microsoft-Web-sites/requests/template.json":
+			w.WriteHeader(github.downloadStatus)
+			w.Write([]byte(`{"title":"` + github.commitSha + `"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return github, server
+}
+
+func (github *fakeGitHub) set(commitSha string, contentsStatus int, downloadStatus int) {
+	github.mutex.Lock()
+	defer github.mutex.Unlock()
+
+	github.commitSha, github.contentsStatus, github.downloadStatus = commitSha, contentsStatus, downloadStatus
+}
+
+func (github *fakeGitHub) hasRequest(request string) bool {
+	github.mutex.Lock()
+	defer github.mutex.Unlock()
+
+	for _, r := range github.requests {
+		if r == request {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestGitHubGrafanaTemplateSource(t *testing.T) {
+	github, server := newFakeGitHub(t)
+	source := NewGitHubGrafanaTemplateSource("owner", "repo", "master")
+	source.apiRootUrl = server.URL
+
+	templateDirs, err := source.getTemplateDirs()
+	if err != nil || !reflect.DeepEqual(templateDirs, []string{"Microsoft-Web-sites"}) {
+		t.Errorf("unexpected template dirs %v, %v", templateDirs, err)
+	}
+
+	// The ref moves on, but the folders and templates are read at the commit resolved first
+	github.set("sha2", http.StatusOK, http.StatusOK)
+	if _, err := source.getTemplateDirs(); err != nil || !github.hasRequest("/repos/owner/repo/contents?ref=sha1") || github.hasRequest("/repos/owner/repo/contents?ref=master") {
+		t.Errorf("expected the template dirs at sha1, got %v", err)
+	}
+
+	dashboardTemplates, err := source.getTemplates("Microsoft-Compute-virtualMachines")
+	if err != nil || len(dashboardTemplates) != 0 {
+		t.Errorf("expected no templates, got %v, %v", dashboardTemplates, err)
+	}
+
+	github.set("sha1", http.StatusForbidden, http.StatusOK)
+	if _, err := source.getTemplateDirs(); err == nil || !strings.Contains(err.Error(), "status code 403") {
+		t.Errorf("expected rate limit error, got %v", err)
+	}
+
+	if _, err := source.getTemplates("Microsoft-Web-sites"); err == nil {
+		t.Errorf("expected rate limit error")
+	}
+
+	github.set("sha1", http.StatusOK, http.StatusInternalServerError)
+	if _, err := source.getTemplates("Microsoft-Web-sites"); err == nil || !strings.Contains(err.Error(), "status code 500") {
+		t.Errorf("expected download error, got %v", err)
+	}
+}
+
+func TestCachedGitHubGrafanaTemplateSource(t *testing.T) {
+	github, server := newFakeGitHub(t)
+	cache, _ := NewGrafanaTemplateCache(t.TempDir())
+	newCachedSource := func() *CachedGrafanaTemplateSource {
+		source := NewGitHubGrafanaTemplateSource("owner", "repo", "master")
+		source.apiRootUrl = server.URL
+		return NewCachedGrafanaTemplateSource(source, cache, false)
+	}
+
+	cachedTemplates := []GrafanaDashboardTemplate{{Name: "requests", Contents: `{"title":"sha1"}`}}
+	tests := []struct {
+		name              string
+		commitSha         string
+		contentsStatus    int
+		downloadStatus    int
+		expectedTemplates []GrafanaDashboardTemplate
+	}{
+		{"failed download is not cached", "sha1", http.StatusOK, http.StatusInternalServerError, nil},
+		{"download is cached", "sha1", http.StatusOK, http.StatusOK, cachedTemplates},
+		{"failed download falls back to cache", "sha2", http.StatusOK, http.StatusNotFound, cachedTemplates},
+		{"rate limit falls back to cache", "sha3", http.StatusForbidden, http.StatusOK, cachedTemplates},
+	}
+
+	for _, test := range tests {
+		github.set(test.commitSha, test.contentsStatus, test.downloadStatus)
+		cachedSource := newCachedSource()
+		dashboardTemplates, err := cachedSource.getTemplates("Microsoft-Web-sites")
+		if (err != nil) != (test.expectedTemplates == nil) || !reflect.DeepEqual(dashboardTemplates, test.expectedTemplates) {
+			t.Errorf("%s: expected templates %v, got %v, %v", test.name, test.expectedTemplates, dashboardTemplates, err)
+		}
+
+		entry, isCached := cache.getEntry(cachedSource.source, "Microsoft-Web-sites")
+		if (test.expectedTemplates == nil && isCached) || (test.expectedTemplates != nil && entry.Version != "sha1") {
+			t.Errorf("%s: unexpected cache entry %+v, %t", test.name, entry, isCached)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Client of the Grafana HTTP API, authenticated with an API key or service account token
+type GrafanaClient struct {
+	baseUrl    string
+	token      string
+	httpClient *http.Client
+}
+
+type GrafanaFolder struct {
+	Id    int    `json:"id"`
+	Uid   string `json:"uid"`
+	Title string `json:"title"`
+}
+
+type GrafanaDashboardRequest struct {
+	Dashboard map[string]interface{} `json:"dashboard"`
+	FolderId  int                    `json:"folderId"`
+	FolderUid string                 `json:"folderUid,omitempty"`
+	Overwrite bool                   `json:"overwrite"`
+	Message   string                 `json:"message,omitempty"`
+}
+
+type GrafanaDashboardResponse struct {
+	Id      int    `json:"id"`
+	Uid     string `json:"uid"`
+	Url     string `json:"url"`
+	Status  string `json:"status"`
+	Version int    `json:"version"`
+}
+
+type GrafanaDashboardGetResponse struct {
+	Dashboard map[string]interface{} `json:"dashboard"`
+}
+
+type GrafanaErrorResponse struct {
+	Message string `json:"message"`
+}
+
+func NewGrafanaClient(baseUrl string, token string) *GrafanaClient {
+	return &GrafanaClient{
+		baseUrl:    strings.TrimSuffix(baseUrl, "/"),
+		token:      token,
+		httpClient: &http.Client{},
+	}
+}
+
+// Find the folder by title and create it if it does not exist
+func (grafanaClient *GrafanaClient) ensureFolder(title string) (GrafanaFolder, error) {
+	var folders []GrafanaFolder
+	_, err := grafanaClient.sendRequest("GET", "/api/folders?limit=1000", nil, &folders)
+	if err != nil {
+		return GrafanaFolder{}, fmt.Errorf("error listing folders: %v", err)
+	}
+
+	for _, folder := range folders {
+		if strings.EqualFold(folder.Title, title) {
+			return folder, nil
+		}
+	}
+
+	var folder GrafanaFolder
+	_, err = grafanaClient.sendRequest("POST", "/api/folders", map[string]string{"title": title}, &folder)
+	if err != nil {
+		return GrafanaFolder{}, fmt.Errorf("error creating folder %s: %v", title, err)
+	}
+
+	log.Infof("Created Grafana folder %s", title)
+	return folder, nil
+}
+
+// Create or overwrite the dashboard in the folder
+func (grafanaClient *GrafanaClient) saveDashboard(dashboard *GrafanaDashboard, folder GrafanaFolder, message string) (GrafanaDashboardResponse, error) {
+	request := GrafanaDashboardRequest{
+		Dashboard: dashboard.ParsedJson,
+		FolderId:  folder.Id,
+		FolderUid: folder.Uid,
+		Overwrite: true,
+		Message:   message,
+	}
+
+	var response GrafanaDashboardResponse
+	_, err := grafanaClient.sendRequest("POST", "/api/dashboards/db", request, &response)
+	return response, err
+}
+
+// Get the version of the dashboard with the uid.  Returns false if the dashboard does not exist.
+func (grafanaClient *GrafanaClient) getDashboardVersion(uid string) (int, bool, error) {
+	var response GrafanaDashboardGetResponse
+	statusCode, err := grafanaClient.sendRequest("GET", "/api/dashboards/uid/"+url.PathEscape(uid), nil, &response)
+	if statusCode == http.StatusNotFound {
+		return 0, false, nil
+	}
+
+	if err != nil {
+		return 0, false, err
+	}
+
+	version, _ := response.Dashboard["version"].(float64)
+	return int(version), true, nil
+}
+
+// Send the request and unmarshal the response body.  Returns the status code along with an error for responses other
+// than 200.
+func (grafanaClient *GrafanaClient) sendRequest(method string, path string, requestBody interface{}, responseBody interface{}) (int, error) {
+	var body []byte
+	if requestBody != nil {
+		var err error
+		body, err = json.Marshal(requestBody)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	request, err := http.NewRequest(method, grafanaClient.baseUrl+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/json")
+	if len(grafanaClient.token) > 0 {
+		request.Header.Set("Authorization", "Bearer "+grafanaClient.token)
+	}
+
+	log.Debugf("Executing %s %s\n", method, request.URL)
+	response, err := grafanaClient.httpClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+
+	log.Debugf("Status code: %d\n", response.StatusCode)
+	defer response.Body.Close()
+	body, err = ioutil.ReadAll(response.Body)
+	if err != nil {
+		return response.StatusCode, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		var errorResponse GrafanaErrorResponse
+		if json.Unmarshal(body, &errorResponse) == nil && len(errorResponse.Message) > 0 {
+			return response.StatusCode, fmt.Errorf("status code %d: %s", response.StatusCode, errorResponse.Message)
+		}
+
+		return response.StatusCode, fmt.Errorf("status code %d", response.StatusCode)
+	}
+
+	return response.StatusCode, json.Unmarshal(body, responseBody)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// In-memory Grafana serving the folder and dashboard APIs used by the API writer.  Saving a dashboard requires the
+// version of the stored dashboard, like Grafana does, and bumps it.
+type fakeGrafana struct {
+	t          *testing.T
+	token      string
+	mutex      sync.Mutex
+	folders    []GrafanaFolder
+	dashboards map[string]GrafanaDashboardRequest
+	requests   []string
+}
+
+func newFakeGrafana(t *testing.T, token string) (*fakeGrafana, *httptest.Server) {
+	grafana := &fakeGrafana{
+		t:          t,
+		token:      token,
+		folders:    []GrafanaFolder{{Id: 1, Uid: "existing", Title: "Existing"}},
+		dashboards: make(map[string]GrafanaDashboardRequest),
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(grafana.serveHTTP))
+	t.Cleanup(server.Close)
+	return grafana, server
+}
+
+func (grafana *fakeGrafana) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	grafana.mutex.Lock()
+	defer grafana.mutex.Unlock()
+
+	grafana.requests = append(grafana.requests, r.Method+" "+r.URL.Path)
+	writeJson := func(statusCode int, value interface{}) {
+		w.WriteHeader(statusCode)
+		json.NewEncoder(w).Encode(value)
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+grafana.token {
+		writeJson(http.StatusUnauthorized, GrafanaErrorResponse{Message: "Invalid API key"})
+		return
+	}
+
+	body, _ := ioutil.ReadAll(r.Body)
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/api/folders":
+		writeJson(http.StatusOK, grafana.folders)
+	case r.Method == "POST" && r.URL.Path == "/api/folders":
+		var request map[string]string
+		json.Unmarshal(body, &request)
+		folder := GrafanaFolder{Id: len(grafana.folders) + 1, Uid: "folder" + request["title"], Title: request["title"]}
+		grafana.folders = append(grafana.folders, folder)
+		writeJson(http.StatusOK, folder)
+	case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/api/dashboards/uid/"):
+		request, ok := grafana.dashboards[strings.TrimPrefix(r.URL.Path, "/api/dashboards/uid/")]
+		if !ok {
+			writeJson(http.StatusNotFound, GrafanaErrorResponse{Message: "Dashboard not found"})
+			return
+		}
+
+		writeJson(http.StatusOK, GrafanaDashboardGetResponse{Dashboard: request.Dashboard})
+	case r.Method == "POST" && r.URL.Path == "/api/dashboards/db":
+		var request GrafanaDashboardRequest
+		json.Unmarshal(body, &request)
+		uid, _ := request.Dashboard["uid"].(string)
+		if title, _ := request.Dashboard["title"].(string); title == "invalid" {
+			writeJson(http.StatusBadRequest, GrafanaErrorResponse{Message: "Dashboard title cannot be empty"})
+			return
+		}
+
+		version, _ := request.Dashboard["version"].(float64)
+		if existing, ok := grafana.dashboards[uid]; ok && existing.Dashboard["version"].(float64) != version && !request.Overwrite {
+			writeJson(http.StatusPreconditionFailed, GrafanaErrorResponse{Message: "version-mismatch"})
+			return
+		}
+
+		request.Dashboard["version"] = version + 1
+		grafana.dashboards[uid] = request
+		writeJson(http.StatusOK, GrafanaDashboardResponse{Uid: uid, Url: "/d/" + uid, Status: "success", Version: int(version + 1)})
+	default:
+		grafana.t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (grafana *fakeGrafana) countRequests(request string) int {
+	grafana.mutex.Lock()
+	defer grafana.mutex.Unlock()
+
+	count := 0
+	for _, r := range grafana.requests {
+		if r == request {
+			count++
+		}
+	}
+
+	return count
+}
+
+func newTestGrafanaDashboard(uid string, title string) *GrafanaDashboard {
+	dashboard := NewGrafanaDashboard(`{"schemaVersion":36,"panels":[],"id":7}`)
+	dashboard.ParsedJson["uid"] = uid
+	dashboard.ParsedJson["title"] = title
+	return dashboard
+}
+
+func TestGrafanaClientEnsureFolder(t *testing.T) {
+	grafana, server := newFakeGrafana(t, "token")
+	grafanaClient := NewGrafanaClient(server.URL+"/", "token")
+
+	tests := []struct {
+		title           string
+		expectedUid     string
+		expectedCreates int
+	}{
+		{"existing", "existing", 0},
+		{"Azure", "folderAzure", 1},
+		{"AZURE", "folderAzure", 1},
+	}
+
+	for _, test := range tests {
+		folder, err := grafanaClient.ensureFolder(test.title)
+		if err != nil || folder.Uid != test.expectedUid {
+			t.Errorf("%s: expected folder %s, got %+v, %v", test.title, test.expectedUid, folder, err)
+		}
+
+		if creates := grafana.countRequests("POST /api/folders"); creates != test.expectedCreates {
+			t.Errorf("%s: expected %d folders created, got %d", test.title, test.expectedCreates, creates)
+		}
+	}
+}
+
+func TestApiGrafanaDashboardWriter(t *testing.T) {
+	grafana, server := newFakeGrafana(t, "token")
+	options := GrafanaGenerateOptions{GrafanaUrl: server.URL, GrafanaToken: "token", GrafanaFolder: "Azure", GrafanaMessage: "Generated"}
+	writer := NewGrafanaDashboardWriter(options, AzureCredentials{}).(*ApiGrafanaDashboardWriter)
+
+	// Created, then overwritten in place twice
+	for i := 0; i < 3; i++ {
+		writer.write(newTestGrafanaDashboard("uid1", "first"), GrafanaDashboardFile{})
+	}
+
+	writer.write(newTestGrafanaDashboard("uid2", "second"), GrafanaDashboardFile{})
+	writer.write(newTestGrafanaDashboard("uid3", "invalid"), GrafanaDashboardFile{})
+
+	if writer.createdCount != 2 || writer.updatedCount != 2 || writer.failedCount != 1 {
+		t.Errorf("expected 2 created, 2 updated and 1 failed, got %d, %d and %d", writer.createdCount, writer.updatedCount, writer.failedCount)
+	}
+
+	saved := grafana.dashboards["uid1"]
+	if saved.Dashboard["version"] != float64(3) || saved.FolderUid != "folderAzure" || saved.Message != "Generated" || !saved.Overwrite {
+		t.Errorf("unexpected saved dashboard %+v", saved)
+	}
+
+	if _, ok := grafana.dashboards["uid3"]; ok {
+		t.Errorf("invalid dashboard was saved")
+	}
+}
+
+func TestGrafanaClientErrors(t *testing.T) {
+	_, server := newFakeGrafana(t, "token")
+	grafanaClient := NewGrafanaClient(server.URL, "wrong")
+
+	_, err := grafanaClient.ensureFolder("Azure")
+	if err == nil || err.Error() != "error listing folders: status code 401: Invalid API key" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	_, _, err = grafanaClient.getDashboardVersion("uid1")
+	if err == nil || !strings.Contains(err.Error(), "Invalid API key") {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	writer := &ApiGrafanaDashboardWriter{grafanaClient: grafanaClient}
+	writer.write(newTestGrafanaDashboard("uid1", "first"), GrafanaDashboardFile{})
+	if writer.failedCount != 1 || writer.createdCount != 0 {
+		t.Errorf("expected the upload to fail, got %+v", writer)
+	}
+
+	grafanaClient = NewGrafanaClient(server.URL, "token")
+	version, exists, err := grafanaClient.getDashboardVersion("missing")
+	if version != 0 || exists || err != nil {
+		t.Errorf("expected missing dashboard, got %d, %t, %v", version, exists, err)
+	}
+}
@@ -1,7 +1,12 @@
 package main
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -20,58 +25,336 @@ func NewGrafanaDashboard(templateContents string) *GrafanaDashboard {
 	return &dashboard
 }
 
-// Update the contents of the Grafana dashboard template with Azure resource IDs
-func (dashboard *GrafanaDashboard) update(title string, dataSourceName string, maxDashboardResources int, armResources []ArmResource, subResourceName string) {
-	var rowsJson []interface{}
-	var panelsJson []interface{}
-	var targetsJson []interface{}
+const (
+	// Each resource is another target of the template panels
+	TargetsGrafanaLayout = "targets"
+	// Each template panel is cloned for each resource
+	PanelsGrafanaLayout = "panels"
+	// Grafana repeats each template panel for each value of a resource template variable
+	RepeatGrafanaLayout = "repeat"
 
+	// Prefix and hash length of the tags shared by the pages of a paged dashboard
+	GrafanaDashboardTagPrefix     = "armclient-"
+	GrafanaDashboardTagHashLength = 16
+
+	// Grafana 5 replaced the rows of the dashboard with a flat list of panels positioned by gridPos
+	GrafanaPanelsSchemaVersion = 16
+)
+
+var GrafanaLayouts = []string{TargetsGrafanaLayout, PanelsGrafanaLayout, RepeatGrafanaLayout}
+
+// Options of the generated Grafana dashboards
+type GrafanaGenerateOptions struct {
+	TitlePrefix           string
+	DataSourceName        string
+	MaxDashboardResources int
+	Layout                string
+	GroupBy               string
+	IsVariablesEnabled    bool
+
+	// Upload the dashboards to Grafana instead of writing files
+	GrafanaUrl     string
+	GrafanaToken   string
+	GrafanaFolder  string
+	GrafanaMessage string
+
+	// Write the dashboards to a folder hierarchy instead of the current directory, optionally with Grafana provisioning
+	// files.  The provisioning path is the output directory as mounted in Grafana, which defaults to the absolute path
+	// of the output directory.
+	OutputDir                       string
+	IsProvisioningEnabled           bool
+	IsDataSourceProvisioningEnabled bool
+	ProvisioningPath                string
+}
+
+// A set of dashboards generated from the same template for the same resources, split into pages.  The pages share a tag
+// so they can link to each other and be listed on the index dashboard.
+type GrafanaPagedDashboard struct {
+	Title     string
+	Tag       string
+	PageCount int
+}
+
+// Update the contents of the Grafana dashboard template with Azure resource IDs, laid out as given
+func (dashboard *GrafanaDashboard) update(title string, dataSourceName string, layout string, armResources []ArmResource, subResourceType string, subResourceName string) {
 	dashboard.ParsedJson["title"] = title
-	rowsJson = dashboard.ParsedJson["rows"].([]interface{})
-	for _, rowJsonObject := range rowsJson {
-		rowJson := rowJsonObject.(map[string]interface{})
-		panelsJson = rowJson["panels"].([]interface{})
-		for _, panelJsonObject := range panelsJson {
-			panelJson := panelJsonObject.(map[string]interface{})
-			panelJson["datasource"] = dataSourceName
-
-			targetsJson = panelJson["targets"].([]interface{})
-			if len(targetsJson) > 0 {
-				// Only the first target matters.
-				targetJson := targetsJson[0].(map[string]interface{})
-				azureMonitorTargetJson := targetJson["azureMonitor"].(map[string]interface{})
-
-				newTargetsJson := make([]map[string]interface{}, 0)
-				upperBound := len(armResources)
-				if maxDashboardResources < upperBound {
-					upperBound = maxDashboardResources
-				}
-
-				// For each ARM resource, we will generate new target
-				for _, armResource := range armResources[:upperBound] {
-					newAzureMonitorTargetJson := copyMap(azureMonitorTargetJson)
-					newAzureMonitorTargetJson["resourceGroup"], _ = armResource.getResourceGroupName()
-
-					// This is a workaround to handle sub-resource cases such as Microsoft.Storage/storageAccounts/blobServices
-					// where ARM does not track the sub-resource "blobServices".
-					// In such case, the resource name is {storageAccountName}/default and it's expected the client passes in
-					// the sub-resource name "default"
-					resourceName := armResource.getResourceName()
-					if len(subResourceName) > 0 {
-						resourceName += "/" + subResourceName
-					}
-
-					newAzureMonitorTargetJson["resourceName"] = resourceName
-
-					newTargetJson := copyMap(targetJson)
-					newTargetJson["azureMonitor"] = newAzureMonitorTargetJson
-					newTargetsJson = append(newTargetsJson, newTargetJson)
-				}
-
-				panelJson["targets"] = newTargetsJson
+
+	dashboard.setDataSource(dataSourceName)
+
+	switch layout {
+	case PanelsGrafanaLayout:
+		dashboard.clonePanels(armResources, subResourceType, subResourceName)
+	case RepeatGrafanaLayout:
+		dashboard.repeatPanels(armResources, subResourceType, subResourceName)
+	default:
+		for _, panelJson := range dashboard.getPanels() {
+			updatePanelTargets(panelJson, armResources, subResourceType, subResourceName)
+		}
+	}
+}
+
+// Point every panel at the data source.  Grafana 8.3+ templates also reference the data source of the template by uid on
+// each target, which is cleared so the targets use the data source of the panel.
+func (dashboard *GrafanaDashboard) setDataSource(dataSourceName string) {
+	for _, panelJson := range dashboard.getPanels() {
+		panelJson["datasource"] = dataSourceName
+
+		targetsJson, _ := panelJson["targets"].([]interface{})
+		for _, targetJson := range targetsJson {
+			if targetJson, ok := targetJson.(map[string]interface{}); ok {
+				delete(targetJson, "datasource")
+			}
+		}
+	}
+}
+
+// Replace the targets of the panel with one target for each ARM resource, based on the first target of the template
+func updatePanelTargets(panelJson map[string]interface{}, armResources []ArmResource, subResourceType string, subResourceName string) {
+	targetJson, ok := getTemplateTarget(panelJson)
+	if !ok {
+		return
+	}
+
+	newTargetsJson := make([]interface{}, 0)
+
+	// For each ARM resource, we will generate new target
+	for _, armResource := range armResources {
+		newTargetsJson = append(newTargetsJson, newAzureMonitorTarget(targetJson, armResource, subResourceType, subResourceName))
+	}
+
+	panelJson["targets"] = newTargetsJson
+}
+
+// Get the first target of the panel if it queries Azure Monitor.  Only the first target matters.
+func getTemplateTarget(panelJson map[string]interface{}) (map[string]interface{}, bool) {
+	targetsJson, _ := panelJson["targets"].([]interface{})
+	if len(targetsJson) == 0 {
+		return nil, false
+	}
+
+	targetJson, _ := targetsJson[0].(map[string]interface{})
+	_, ok := targetJson["azureMonitor"].(map[string]interface{})
+	return targetJson, ok
+}
+
+// Copy the template target for the ARM resource
+func newAzureMonitorTarget(targetJson map[string]interface{}, armResource ArmResource, subResourceType string, subResourceName string) map[string]interface{} {
+	subscriptionId, _ := armResource.getSubscriptionId()
+	resourceGroupName, _ := armResource.getResourceGroupName()
+
+	// This is a workaround to handle sub-resource cases such as Microsoft.Storage/storageAccounts/blobServices
+	// where ARM does not track the sub-resource "blobServices".
+	// In such case, the resource name is {storageAccountName}/default and it's expected the client passes in
+	// the sub-resource name "default"
+	resourceName := armResource.getResourceName()
+	if len(subResourceName) > 0 {
+		resourceName += "/" + subResourceName
+	}
+
+	return newAzureMonitorResourceTarget(targetJson, subscriptionId, resourceGroupName, resourceName, getMetricsResourceId(armResource, subResourceType, subResourceName), armResource.Location)
+}
+
+// Copy the template target for the resource.  Besides the resource group and name, Grafana 9 templates identify the
+// resource by resourceUri and Grafana 10 templates by a list of resources, which take precedence and are rewritten too.
+// The region is left out if empty.
+func newAzureMonitorResourceTarget(targetJson map[string]interface{}, subscriptionId string, resourceGroupName string, resourceName string, resourceUri string, region string) map[string]interface{} {
+	newAzureMonitorTargetJson := copyMap(targetJson["azureMonitor"].(map[string]interface{}))
+	newAzureMonitorTargetJson["resourceGroup"] = resourceGroupName
+	newAzureMonitorTargetJson["resourceName"] = resourceName
+
+	if _, ok := newAzureMonitorTargetJson["resourceUri"]; ok {
+		newAzureMonitorTargetJson["resourceUri"] = resourceUri
+	}
+
+	if resourcesJson, ok := newAzureMonitorTargetJson["resources"].([]interface{}); ok {
+		resourceJson := make(map[string]interface{})
+		if len(resourcesJson) > 0 {
+			if templateResourceJson, ok := resourcesJson[0].(map[string]interface{}); ok {
+				resourceJson = copyMap(templateResourceJson)
 			}
 		}
+
+		resourceJson["subscription"] = subscriptionId
+		resourceJson["resourceGroup"] = resourceGroupName
+		resourceJson["resourceName"] = resourceName
+		delete(resourceJson, "region")
+		if len(region) > 0 {
+			resourceJson["region"] = region
+		}
+
+		newAzureMonitorTargetJson["resources"] = []interface{}{resourceJson}
+	}
+
+	newTargetJson := copyMap(targetJson)
+	if _, ok := newTargetJson["subscription"]; ok {
+		newTargetJson["subscription"] = subscriptionId
 	}
+
+	newTargetJson["azureMonitor"] = newAzureMonitorTargetJson
+	return newTargetJson
+}
+
+// Identify the dashboard by a uid derived from its name, so regenerating the dashboard updates it in place rather than
+// creating a duplicate.  The id of the template refers to a dashboard on another Grafana instance and is cleared.
+func (dashboard *GrafanaDashboard) setUid(nameParts ...string) {
+	dashboard.ParsedJson["uid"] = getGrafanaDashboardUid(nameParts...)
+	dashboard.ParsedJson["id"] = nil
+}
+
+// The SHA-1 of the name parts fits the 40 characters Grafana allows for uids
+func getGrafanaDashboardUid(nameParts ...string) string {
+	hash := sha1.Sum([]byte(strings.ToLower(strings.Join(nameParts, "|"))))
+	return hex.EncodeToString(hash[:])
+}
+
+// Grafana stores tags in 50 characters, so the tag of a paged dashboard is derived from its name parts rather than its
+// title, e.g. armclient-0b5c9a1e2d3f4a5b
+func getGrafanaDashboardTag(nameParts ...string) string {
+	return GrafanaDashboardTagPrefix + getGrafanaDashboardUid(nameParts...)[:GrafanaDashboardTagHashLength]
+}
+
+// Tag the page and link it to the other pages of the paged dashboard
+func (dashboard *GrafanaDashboard) addPageLinks(pagedDashboard GrafanaPagedDashboard) {
+	tagsJson, _ := dashboard.ParsedJson["tags"].([]interface{})
+	dashboard.ParsedJson["tags"] = append(tagsJson, pagedDashboard.Tag)
+
+	linksJson, _ := dashboard.ParsedJson["links"].([]interface{})
+	dashboard.ParsedJson["links"] = append(linksJson, map[string]interface{}{
+		"type":       "dashboards",
+		"title":      "Pages",
+		"tags":       []string{pagedDashboard.Tag},
+		"asDropdown": true,
+		"keepTime":   true,
+	})
+}
+
+// Create a dashboard listing the pages of each paged dashboard
+func NewGrafanaIndexDashboard(title string, pagedDashboards []GrafanaPagedDashboard) *GrafanaDashboard {
+	const panelHeight = 8
+
+	panelsJson := make([]interface{}, 0)
+	for index, pagedDashboard := range pagedDashboards {
+		panelsJson = append(panelsJson, map[string]interface{}{
+			"id":    index + 1,
+			"type":  "dashlist",
+			"title": fmt.Sprintf("%s (%d pages)", pagedDashboard.Title, pagedDashboard.PageCount),
+			"gridPos": map[string]interface{}{
+				"x": 0,
+				"y": index * panelHeight,
+				"w": 24,
+				"h": panelHeight,
+			},
+			"options": map[string]interface{}{
+				"showSearch":   true,
+				"showHeadings": false,
+				"tags":         []string{pagedDashboard.Tag},
+				"maxItems":     pagedDashboard.PageCount,
+			},
+			// Used by Grafana before 7
+			"search": true,
+			"tags":   []string{pagedDashboard.Tag},
+			"limit":  pagedDashboard.PageCount,
+		})
+	}
+
+	return &GrafanaDashboard{
+		ParsedJson: map[string]interface{}{
+			"title":         title,
+			"schemaVersion": GrafanaPanelsSchemaVersion,
+			"panels":        panelsJson,
+		},
+	}
+}
+
+// Split the ARM resources into pages of at most pageSize resources, ordered by resource ID so regenerating the
+// dashboards keeps each resource on the same page
+func getArmResourcePages(armResources []ArmResource, pageSize int) [][]ArmResource {
+	sortedArmResources := make([]ArmResource, len(armResources))
+	copy(sortedArmResources, armResources)
+	sort.SliceStable(sortedArmResources, func(i, j int) bool {
+		return strings.ToLower(sortedArmResources[i].Id) < strings.ToLower(sortedArmResources[j].Id)
+	})
+
+	if pageSize <= 0 || len(sortedArmResources) <= pageSize {
+		return [][]ArmResource{sortedArmResources}
+	}
+
+	pages := make([][]ArmResource, 0)
+	for start := 0; start < len(sortedArmResources); start += pageSize {
+		end := start + pageSize
+		if end > len(sortedArmResources) {
+			end = len(sortedArmResources)
+		}
+
+		pages = append(pages, sortedArmResources[start:end])
+	}
+
+	return pages
+}
+
+// Returns whether the dashboard uses the rows schema of Grafana 4 and earlier.  Dashboards without schemaVersion are
+// detected by their top-level properties.
+func (dashboard *GrafanaDashboard) isRowsSchema() bool {
+	if schemaVersion, ok := dashboard.ParsedJson["schemaVersion"].(float64); ok {
+		return schemaVersion < GrafanaPanelsSchemaVersion
+	}
+
+	_, hasPanels := dashboard.ParsedJson["panels"]
+	_, hasRows := dashboard.ParsedJson["rows"]
+	return hasRows && !hasPanels
+}
+
+// Get the panels of the dashboard, excluding the row panels themselves.  Both the legacy rows schema and the panels
+// schema are supported, including the panels nested in collapsed rows.
+func (dashboard *GrafanaDashboard) getPanels() []map[string]interface{} {
+	panels := make([]map[string]interface{}, 0)
+	if dashboard.isRowsSchema() {
+		rowsJson, _ := dashboard.ParsedJson["rows"].([]interface{})
+		for _, rowJsonObject := range rowsJson {
+			rowJson, _ := rowJsonObject.(map[string]interface{})
+			panels = append(panels, getPanelObjects(rowJson["panels"])...)
+		}
+
+		return panels
+	}
+
+	for _, panelJson := range getPanelObjects(dashboard.ParsedJson["panels"]) {
+		if panelJson["type"] == "row" {
+			// Collapsed rows hold their panels, expanded rows are followed by them
+			panels = append(panels, getPanelObjects(panelJson["panels"])...)
+			continue
+		}
+
+		panels = append(panels, panelJson)
+	}
+
+	return panels
+}
+
+func getPanelObjects(panelsJsonObject interface{}) []map[string]interface{} {
+	panels := make([]map[string]interface{}, 0)
+	panelsJson, _ := panelsJsonObject.([]interface{})
+	for _, panelJsonObject := range panelsJson {
+		if panelJson, ok := panelJsonObject.(map[string]interface{}); ok {
+			panels = append(panels, panelJson)
+		}
+	}
+
+	return panels
+}
+
+// Get the azureMonitor section of each target of the panel
+func getAzureMonitorTargets(panelJson map[string]interface{}) []map[string]interface{} {
+	azureMonitorTargets := make([]map[string]interface{}, 0)
+	targetsJson, _ := panelJson["targets"].([]interface{})
+	for _, targetJsonObject := range targetsJson {
+		targetJson, _ := targetJsonObject.(map[string]interface{})
+		if azureMonitorTargetJson, ok := targetJson["azureMonitor"].(map[string]interface{}); ok {
+			azureMonitorTargets = append(azureMonitorTargets, azureMonitorTargetJson)
+		}
+	}
+
+	return azureMonitorTargets
 }
 
 func copyMap(originalMap map[string]interface{}) map[string]interface{} {
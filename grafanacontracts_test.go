@@ -0,0 +1,324 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testAzureMonitorTarget = `{"refId":"A","datasource":{"type":"grafana-azure-monitor-datasource","uid":"template-uid"},"azureMonitor":{"metricName":"Transactions","resourceGroup":"template-rg","resourceName":"template"}}`
+
+func getPanelTitles(panels []map[string]interface{}) []string {
+	titles := make([]string, 0)
+	for _, panelJson := range panels {
+		title, _ := panelJson["title"].(string)
+		titles = append(titles, title)
+	}
+
+	return titles
+}
+
+func TestGetPanels(t *testing.T) {
+	tests := []struct {
+		name                 string
+		template             string
+		expectedIsRowsSchema bool
+		expectedTitles       []string
+	}{
+		{
+			name:                 "rows",
+			template:             `{"schemaVersion":14,"rows":[{"panels":[{"title":"a"},{"title":"b"}]},{"panels":[{"title":"c"}]}]}`,
+			expectedIsRowsSchema: true,
+			expectedTitles:       []string{"a", "b", "c"},
+		},
+		{
+			name:                 "rows without schema version",
+			template:             `{"rows":[{"panels":[{"title":"a"}]}]}`,
+			expectedIsRowsSchema: true,
+			expectedTitles:       []string{"a"},
+		},
+		{
+			name:                 "rows without panels",
+			template:             `{"schemaVersion":14,"rows":[{"title":"empty"},null,{"panels":null}]}`,
+			expectedIsRowsSchema: true,
+			expectedTitles:       []string{},
+		},
+		{
+			name:                 "flat panels",
+			template:             `{"schemaVersion":27,"panels":[{"title":"a","gridPos":{"x":0,"y":0,"w":12,"h":8}},{"title":"b"}]}`,
+			expectedIsRowsSchema: false,
+			expectedTitles:       []string{"a", "b"},
+		},
+		{
+			name:                 "panels without schema version",
+			template:             `{"panels":[{"title":"a"}]}`,
+			expectedIsRowsSchema: false,
+			expectedTitles:       []string{"a"},
+		},
+		{
+			name:                 "expanded and collapsed rows",
+			template:             `{"schemaVersion":36,"panels":[{"type":"row","title":"expanded","collapsed":false,"panels":[]},{"title":"a"},{"type":"row","title":"collapsed","collapsed":true,"panels":[{"title":"b"},{"title":"c"}]}]}`,
+			expectedIsRowsSchema: false,
+			expectedTitles:       []string{"a", "b", "c"},
+		},
+		{
+			name:                 "no panels",
+			template:             `{"schemaVersion":36}`,
+			expectedIsRowsSchema: false,
+			expectedTitles:       []string{},
+		},
+	}
+
+	for _, test := range tests {
+		dashboard := NewGrafanaDashboard(test.template)
+		if dashboard.isRowsSchema() != test.expectedIsRowsSchema {
+			t.Errorf("%s: expected rows schema %t", test.name, test.expectedIsRowsSchema)
+		}
+
+		if titles := getPanelTitles(dashboard.getPanels()); !reflect.DeepEqual(titles, test.expectedTitles) {
+			t.Errorf("%s: expected panels %v, got %v", test.name, test.expectedTitles, titles)
+		}
+	}
+}
+
+func TestUpdateSetsDataSource(t *testing.T) {
+	templates := map[string]string{
+		"rows":           `{"schemaVersion":14,"rows":[{"panels":[{"title":"a","datasource":"template","targets":[` + testAzureMonitorTarget + `]}]}]}`,
+		"flat panels":    `{"schemaVersion":36,"panels":[{"title":"a","datasource":{"uid":"template-uid"},"targets":[` + testAzureMonitorTarget + `]}]}`,
+		"collapsed rows": `{"schemaVersion":36,"panels":[{"type":"row","collapsed":true,"panels":[{"title":"a","datasource":{"uid":"template-uid"},"targets":[` + testAzureMonitorTarget + `]}]}]}`,
+	}
+
+	armResources := []ArmResource{
+		{Id: "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Storage/storageAccounts/a"},
+		{Id: "/subscriptions/sub1/resourceGroups/rg2/providers/Microsoft.Storage/storageAccounts/b"},
+	}
+
+	for name, template := range templates {
+		for _, layout := range []string{TargetsGrafanaLayout, PanelsGrafanaLayout} {
+			dashboard := NewGrafanaDashboard(template)
+			dashboard.update("title", "Azure Monitor", layout, armResources, "", "")
+
+			resourceNames := make([]string, 0)
+			for _, panelJson := range dashboard.getPanels() {
+				if panelJson["datasource"] != "Azure Monitor" {
+					t.Errorf("%s, %s: unexpected panel datasource %v", name, layout, panelJson["datasource"])
+				}
+
+				for _, targetJsonObject := range panelJson["targets"].([]interface{}) {
+					targetJson := targetJsonObject.(map[string]interface{})
+					if _, ok := targetJson["datasource"]; ok {
+						t.Errorf("%s, %s: target datasource not cleared: %v", name, layout, targetJson["datasource"])
+					}
+
+					azureMonitorJson := targetJson["azureMonitor"].(map[string]interface{})
+					resourceNames = append(resourceNames, azureMonitorJson["resourceGroup"].(string)+"/"+azureMonitorJson["resourceName"].(string))
+				}
+			}
+
+			if !reflect.DeepEqual(resourceNames, []string{"rg1/a", "rg2/b"}) {
+				t.Errorf("%s, %s: unexpected targets %v", name, layout, resourceNames)
+			}
+		}
+	}
+}
+
+func TestGetArmResourcePages(t *testing.T) {
+	armResources := make([]ArmResource, 0)
+	for _, name := range []string{"e", "B", "a", "d", "C"} {
+		armResources = append(armResources, ArmResource{Id: "/subscriptions/sub1/resourceGroups/rg/providers/A/b/" + name})
+	}
+
+	tests := []struct {
+		pageSize      int
+		expectedPages [][]string
+	}{
+		{0, [][]string{{"a", "B", "C", "d", "e"}}},
+		{-1, [][]string{{"a", "B", "C", "d", "e"}}},
+		{5, [][]string{{"a", "B", "C", "d", "e"}}},
+		{10, [][]string{{"a", "B", "C", "d", "e"}}},
+		{2, [][]string{{"a", "B"}, {"C", "d"}, {"e"}}},
+		{1, [][]string{{"a"}, {"B"}, {"C"}, {"d"}, {"e"}}},
+	}
+
+	for _, test := range tests {
+		pages := make([][]string, 0)
+		for _, page := range getArmResourcePages(armResources, test.pageSize) {
+			names := make([]string, 0)
+			for _, armResource := range page {
+				names = append(names, armResource.getResourceName())
+			}
+
+			pages = append(pages, names)
+		}
+
+		if !reflect.DeepEqual(pages, test.expectedPages) {
+			t.Errorf("page size %d: expected %v, got %v", test.pageSize, test.expectedPages, pages)
+		}
+	}
+
+	if armResources[0].getResourceName() != "e" {
+		t.Errorf("the resources were sorted in place")
+	}
+
+	if pages := getArmResourcePages([]ArmResource{}, 10); len(pages) != 1 || len(pages[0]) != 0 {
+		t.Errorf("expected a single empty page, got %v", pages)
+	}
+}
+
+func TestGetGrafanaDashboardTag(t *testing.T) {
+	longPrefix := "A very long dashboard title prefix that exceeds the limit on its own"
+	tag := getGrafanaDashboardTag(longPrefix, "Microsoft.Storage/storageAccounts/blobServices", "Storage Account Transactions", "allregions")
+	if len(tag) > 50 || len(tag) != len(GrafanaDashboardTagPrefix)+GrafanaDashboardTagHashLength {
+		t.Errorf("unexpected tag length %d: %s", len(tag), tag)
+	}
+
+	if tag != getGrafanaDashboardTag(strings.ToUpper(longPrefix), "microsoft.storage/storageaccounts/blobservices", "Storage Account Transactions", "ALLREGIONS") {
+		t.Errorf("tag is not stable across casing")
+	}
+
+	if tag == getGrafanaDashboardTag(longPrefix, "Microsoft.Storage/storageAccounts/blobServices", "Storage Account Transactions", "westus") {
+		t.Errorf("groups share the tag %s", tag)
+	}
+
+	dashboard := NewGrafanaDashboard(`{"schemaVersion":36,"tags":["existing"]}`)
+	dashboard.addPageLinks(GrafanaPagedDashboard{Title: "title", Tag: tag, PageCount: 2})
+	if !reflect.DeepEqual(dashboard.ParsedJson["tags"], []interface{}{"existing", tag}) {
+		t.Errorf("unexpected tags %v", dashboard.ParsedJson["tags"])
+	}
+}
+
+func TestCheckLayout(t *testing.T) {
+	tests := []struct {
+		template      string
+		layout        string
+		expectedError bool
+	}{
+		{`{"schemaVersion":14,"rows":[]}`, TargetsGrafanaLayout, false},
+		{`{"schemaVersion":14,"rows":[]}`, PanelsGrafanaLayout, false},
+		{`{"schemaVersion":14,"rows":[]}`, RepeatGrafanaLayout, true},
+		{`{"rows":[]}`, RepeatGrafanaLayout, true},
+		{`{"panels":[]}`, RepeatGrafanaLayout, true},
+		{`{"schemaVersion":27,"panels":[]}`, RepeatGrafanaLayout, true},
+		{`{"schemaVersion":36,"panels":[]}`, RepeatGrafanaLayout, false},
+		{`{"schemaVersion":39,"panels":[]}`, RepeatGrafanaLayout, false},
+	}
+
+	for _, test := range tests {
+		err := NewGrafanaDashboard(test.template).checkLayout(test.layout)
+		if (err != nil) != test.expectedError {
+			t.Errorf("%s, %s: expected error %t, got %v", test.template, test.layout, test.expectedError, err)
+		}
+	}
+}
+
+func TestGetGrafanaDashboardUid(t *testing.T) {
+	tests := []struct {
+		nameParts        []string
+		otherNameParts   []string
+		expectedSameUids bool
+	}{
+		{[]string{"prefix", "Microsoft.Storage/storageAccounts", "transactions", "westus"}, []string{"prefix", "Microsoft.Storage/storageAccounts", "transactions", "westus"}, true},
+		{[]string{"Prefix", "Microsoft.Storage/storageAccounts", "Transactions", "WestUS"}, []string{"prefix", "microsoft.storage/storageaccounts", "transactions", "westus"}, true},
+		{[]string{"prefix", "Microsoft.Storage/storageAccounts", "transactions", "westus"}, []string{"prefix", "Microsoft.Storage/storageAccounts", "transactions", "eastus"}, false},
+		{[]string{"prefix", "Microsoft.Storage/storageAccounts", "transactions", "1"}, []string{"prefix", "Microsoft.Storage/storageAccounts", "transactions", "2"}, false},
+		{[]string{"a|b", "c"}, []string{"a", "b|c"}, true},
+		{[]string{"ab", "c"}, []string{"a", "bc"}, false},
+	}
+
+	for _, test := range tests {
+		uid := getGrafanaDashboardUid(test.nameParts...)
+		if len(uid) != 40 || strings.Trim(uid, "0123456789abcdef") != "" {
+			t.Errorf("%v: uid %s is not 40 lowercase hex characters", test.nameParts, uid)
+		}
+
+		if (uid == getGrafanaDashboardUid(test.otherNameParts...)) != test.expectedSameUids {
+			t.Errorf("%v, %v: expected same uids %t", test.nameParts, test.otherNameParts, test.expectedSameUids)
+		}
+	}
+
+	dashboard := NewGrafanaDashboard(`{"schemaVersion":36,"id":12,"uid":"template"}`)
+	dashboard.setUid("prefix", "transactions")
+	if dashboard.ParsedJson["uid"] != getGrafanaDashboardUid("prefix", "transactions") || dashboard.ParsedJson["id"] != nil {
+		t.Errorf("unexpected uid %v and id %v", dashboard.ParsedJson["uid"], dashboard.ParsedJson["id"])
+	}
+}
+
+// Azure Monitor target of Grafana 10 templates, identifying the resource by resourceUri and by a list of resources
+const testAzureMonitorResourcesTarget = `{"refId":"A","subscription":"template-sub","azureMonitor":{"metricName":"Transactions","metricNamespace":"Microsoft.Storage/storageAccounts/blobServices","resourceGroup":"template-rg","resourceName":"template/default","resourceUri":"/subscriptions/template-sub/resourceGroups/template-rg/providers/Microsoft.Storage/storageAccounts/template/blobServices/default","resources":[{"subscription":"template-sub","resourceGroup":"template-rg","resourceName":"template/default","metricNamespace":"Microsoft.Storage/storageAccounts/blobServices","region":"eastus"}]}}`
+
+func getAzureMonitorResources(t *testing.T, dashboard *GrafanaDashboard) []string {
+	resources := make([]string, 0)
+	for _, panelJson := range dashboard.getPanels() {
+		for _, targetJsonObject := range panelJson["targets"].([]interface{}) {
+			targetJson := targetJsonObject.(map[string]interface{})
+			azureMonitorJson := targetJson["azureMonitor"].(map[string]interface{})
+			resourcesJson := azureMonitorJson["resources"].([]interface{})
+			if len(resourcesJson) != 1 {
+				t.Fatalf("expected a single resource, got %v", resourcesJson)
+			}
+
+			resourceJson := resourcesJson[0].(map[string]interface{})
+			resources = append(resources, strings.Join([]string{
+				targetJson["subscription"].(string),
+				azureMonitorJson["resourceUri"].(string),
+				resourceJson["subscription"].(string),
+				resourceJson["resourceGroup"].(string),
+				resourceJson["resourceName"].(string),
+				resourceJson["metricNamespace"].(string),
+				fmt.Sprint(resourceJson["region"]),
+			}, " "))
+		}
+	}
+
+	return resources
+}
+
+func TestUpdateRewritesResourceUri(t *testing.T) {
+	template := `{"schemaVersion":38,"panels":[{"title":"a","targets":[` + testAzureMonitorResourcesTarget + `]}]}`
+	armResources := []ArmResource{
+		{Id: "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Storage/storageAccounts/a", Location: "westus"},
+		{Id: "/subscriptions/sub2/resourceGroups/rg2/providers/Microsoft.Storage/storageAccounts/b"},
+	}
+
+	expectedResources := []string{
+		"sub1 /subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Storage/storageAccounts/a/blobServices/default sub1 rg1 a/default Microsoft.Storage/storageAccounts/blobServices westus",
+		"sub2 /subscriptions/sub2/resourceGroups/rg2/providers/Microsoft.Storage/storageAccounts/b/blobServices/default sub2 rg2 b/default Microsoft.Storage/storageAccounts/blobServices <nil>",
+	}
+
+	for _, layout := range []string{TargetsGrafanaLayout, PanelsGrafanaLayout} {
+		dashboard := NewGrafanaDashboard(template)
+		dashboard.update("title", "Azure Monitor", layout, armResources, "blobServices", "default")
+		if resources := getAzureMonitorResources(t, dashboard); !reflect.DeepEqual(resources, expectedResources) {
+			t.Errorf("%s: expected resources %v, got %v", layout, expectedResources, resources)
+		}
+	}
+
+	dashboard := NewGrafanaDashboard(template)
+	dashboard.updateWithVariables("title", "Azure Monitor", "sub1", "Microsoft.Storage/storageAccounts", "blobServices", "default")
+	expectedResources = []string{
+		"$subscription /subscriptions/$subscription/resourceGroups/$resourceGroup/providers/Microsoft.Storage/storageAccounts/$resource/blobServices/default $subscription $resourceGroup $resource/default Microsoft.Storage/storageAccounts/blobServices <nil>",
+	}
+
+	if resources := getAzureMonitorResources(t, dashboard); !reflect.DeepEqual(resources, expectedResources) {
+		t.Errorf("variables: expected resources %v, got %v", expectedResources, resources)
+	}
+}
+
+func TestRepeatPanelsQueriesResourceUri(t *testing.T) {
+	dashboard := NewGrafanaDashboard(`{"schemaVersion":38,"panels":[{"title":"a","targets":[` + testAzureMonitorResourcesTarget + `]}]}`)
+	armResources := []ArmResource{{Id: "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Storage/storageAccounts/a"}}
+	dashboard.update("title", "Azure Monitor", RepeatGrafanaLayout, armResources, "blobServices", "default")
+
+	panelJson := dashboard.getPanels()[0]
+	azureMonitorJson := panelJson["targets"].([]interface{})[0].(map[string]interface{})["azureMonitor"].(map[string]interface{})
+	for _, key := range []string{"resourceGroup", "resourceName", "resources"} {
+		if _, ok := azureMonitorJson[key]; ok {
+			t.Errorf("%s not removed: %v", key, azureMonitorJson[key])
+		}
+	}
+
+	if azureMonitorJson["resourceUri"] != "$"+GrafanaResourceVariableName || panelJson["repeat"] != GrafanaResourceVariableName {
+		t.Errorf("unexpected repeated panel %v", panelJson)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	RegionGrafanaGroupBy        = "region"
+	ResourceGroupGrafanaGroupBy = "resourcegroup"
+	SubscriptionGrafanaGroupBy  = "subscription"
+	TagGrafanaGroupByPrefix     = "tag:"
+	NoneGrafanaGroupBy          = "none"
+
+	// The group of the resources lacking the tag when grouping by tag
+	UntaggedGrafanaGroupName = "untagged"
+
+	// The group of the resources without group name, e.g. without location when grouping by region
+	UnknownGrafanaGroupName = "unknown"
+
+	// The single group when not grouping
+	AllGrafanaGroupName = "all"
+)
+
+// The resources of a dashboard, e.g. of a region
+type GrafanaDashboardGroup struct {
+	Name         string
+	ArmResources []ArmResource
+}
+
+// Get the group name of an ARM resource for the given group by, or an error for an unknown group by
+func getGrafanaGroupNameFunc(groupBy string) (func(armResource ArmResource) string, error) {
+	switch {
+	case groupBy == RegionGrafanaGroupBy:
+		return func(armResource ArmResource) string {
+			return armResource.Location
+		}, nil
+	case groupBy == ResourceGroupGrafanaGroupBy:
+		return func(armResource ArmResource) string {
+			resourceGroupName, _ := armResource.getResourceGroupName()
+			return resourceGroupName
+		}, nil
+	case groupBy == SubscriptionGrafanaGroupBy:
+		return func(armResource ArmResource) string {
+			subscriptionId, _ := armResource.getSubscriptionId()
+			return subscriptionId
+		}, nil
+	case strings.HasPrefix(groupBy, TagGrafanaGroupByPrefix) && len(groupBy) > len(TagGrafanaGroupByPrefix):
+		tagKey := strings.TrimPrefix(groupBy, TagGrafanaGroupByPrefix)
+		return func(armResource ArmResource) string {
+			tagValue, ok := armResource.getTagValue(tagKey)
+			if !ok || len(tagValue) == 0 {
+				return UntaggedGrafanaGroupName
+			}
+
+			return tagValue
+		}, nil
+	case groupBy == NoneGrafanaGroupBy:
+		return func(armResource ArmResource) string {
+			return AllGrafanaGroupName
+		}, nil
+	default:
+		return nil, fmt.Errorf("Unknown group by: %s", groupBy)
+	}
+}
+
+// Check the group by before any resource is fetched
+func validateGrafanaGroupBy(groupBy string) error {
+	_, err := getGrafanaGroupNameFunc(groupBy)
+	return err
+}
+
+// Group the ARM resources into dashboards.  Grouping by region adds a dashboard for all regions, and resources lacking
+// the tag are grouped together when grouping by tag.  Resources without group name, e.g. without location, are grouped
+// together too rather than left out.
+func getGrafanaDashboardGroups(armResources []ArmResource, groupBy string) ([]GrafanaDashboardGroup, error) {
+	getGroupName, err := getGrafanaGroupNameFunc(groupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	if groupBy == NoneGrafanaGroupBy {
+		return []GrafanaDashboardGroup{{Name: AllGrafanaGroupName, ArmResources: armResources}}, nil
+	}
+
+	// Group names are case-insensitive, the first seen casing is used
+	groups := make([]GrafanaDashboardGroup, 0)
+	groupIndexes := make(map[string]int)
+	for _, armResource := range armResources {
+		groupName := getGroupName(armResource)
+		if len(groupName) == 0 {
+			groupName = UnknownGrafanaGroupName
+		}
+
+		index, ok := groupIndexes[strings.ToLower(groupName)]
+		if !ok {
+			index = len(groups)
+			groupIndexes[strings.ToLower(groupName)] = index
+			groups = append(groups, GrafanaDashboardGroup{Name: groupName})
+		}
+
+		groups[index].ArmResources = append(groups[index].ArmResources, armResource)
+	}
+
+	if groupBy == RegionGrafanaGroupBy {
+		groups = append(groups, GrafanaDashboardGroup{Name: "allregions", ArmResources: armResources})
+	}
+
+	return groups, nil
+}
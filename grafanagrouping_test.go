@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetGrafanaDashboardGroups(t *testing.T) {
+	armResources := []ArmResource{
+		{Id: "/subscriptions/sub1/resourceGroups/rg1/providers/A/b/a", Location: "westus", Tags: map[string]string{"Application": "web"}},
+		{Id: "/subscriptions/sub1/resourceGroups/RG1/providers/A/b/b", Location: "WestUS", Tags: map[string]string{"application": "Web"}},
+		{Id: "/subscriptions/sub2/resourceGroups/rg2/providers/A/b/c", Location: "eastus", Tags: map[string]string{"application": ""}},
+		{Id: "/subscriptions/sub2/resourceGroups/rg2/providers/A/b/d", Location: ""},
+		{Id: "/providers/A/b/e", Location: "global"},
+	}
+
+	tests := []struct {
+		groupBy        string
+		expectedGroups map[string][]string
+		expectedError  bool
+	}{
+		{RegionGrafanaGroupBy, map[string][]string{"westus": {"a", "b"}, "eastus": {"c"}, "unknown": {"d"}, "global": {"e"}, "allregions": {"a", "b", "c", "d", "e"}}, false},
+		{ResourceGroupGrafanaGroupBy, map[string][]string{"rg1": {"a", "b"}, "rg2": {"c", "d"}, "unknown": {"e"}}, false},
+		{SubscriptionGrafanaGroupBy, map[string][]string{"sub1": {"a", "b"}, "sub2": {"c", "d"}, "unknown": {"e"}}, false},
+		{"tag:application", map[string][]string{"web": {"a", "b"}, "untagged": {"c", "d", "e"}}, false},
+		{NoneGrafanaGroupBy, map[string][]string{"all": {"a", "b", "c", "d", "e"}}, false},
+		{"tag:", nil, true},
+		{"location", nil, true},
+		{"", nil, true},
+	}
+
+	for _, test := range tests {
+		if err := validateGrafanaGroupBy(test.groupBy); (err != nil) != test.expectedError {
+			t.Errorf("%q: expected error %t, got %v", test.groupBy, test.expectedError, err)
+		}
+
+		groups, err := getGrafanaDashboardGroups(armResources, test.groupBy)
+		if (err != nil) != test.expectedError {
+			t.Errorf("%q: expected error %t, got %v", test.groupBy, test.expectedError, err)
+			continue
+		}
+
+		if test.expectedError {
+			continue
+		}
+
+		resourceCount := 0
+		groupNames := make(map[string][]string)
+		for _, group := range groups {
+			for _, armResource := range group.ArmResources {
+				groupNames[group.Name] = append(groupNames[group.Name], armResource.getResourceName())
+			}
+
+			if group.Name != "allregions" {
+				resourceCount += len(group.ArmResources)
+			}
+		}
+
+		if !reflect.DeepEqual(groupNames, test.expectedGroups) {
+			t.Errorf("%q: expected groups %v, got %v", test.groupBy, test.expectedGroups, groupNames)
+		}
+
+		if resourceCount != len(armResources) {
+			t.Errorf("%q: expected every resource in one group, got %d resources", test.groupBy, resourceCount)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	GrafanaGridWidth = 24
+
+	// The template variable the panels are repeated by in the repeat layout
+	GrafanaResourceVariableName = "resource"
+
+	// Grafana 9 is the first version whose Azure Monitor data source queries a resource by resourceUri
+	GrafanaResourceUriSchemaVersion = 36
+)
+
+// Tracks the position of the next panel while laying out a dashboard of the panels schema
+type GrafanaPanelLayout struct {
+	nextId int
+	y      int
+}
+
+// Clone each template panel for each ARM resource.  Clones of the panels schema are placed next to each other, wrapping
+// to the next line when the grid is full.  Clones of the rows schema stay in the row of the template panel.
+func (dashboard *GrafanaDashboard) clonePanels(armResources []ArmResource, subResourceType string, subResourceName string) {
+	layout := &GrafanaPanelLayout{nextId: 1}
+
+	if dashboard.isRowsSchema() {
+		rowsJson, _ := dashboard.ParsedJson["rows"].([]interface{})
+		for _, rowJsonObject := range rowsJson {
+			if rowJson, ok := rowJsonObject.(map[string]interface{}); ok {
+				rowJson["panels"] = layout.clonePanels(getPanelObjects(rowJson["panels"]), armResources, subResourceType, subResourceName, false)
+			}
+		}
+
+		return
+	}
+
+	newPanelsJson := make([]interface{}, 0)
+	for _, panelJson := range getPanelObjects(dashboard.ParsedJson["panels"]) {
+		if panelJson["type"] != "row" {
+			newPanelsJson = append(newPanelsJson, layout.clonePanels([]map[string]interface{}{panelJson}, armResources, subResourceType, subResourceName, true)...)
+			continue
+		}
+
+		panelJson["id"] = layout.nextId
+		panelJson["gridPos"] = map[string]interface{}{"x": 0, "y": layout.y, "w": GrafanaGridWidth, "h": 1}
+		layout.nextId++
+		layout.y++
+		newPanelsJson = append(newPanelsJson, panelJson)
+
+		// The panels of collapsed rows are positioned as if the row was expanded, without moving the following panels
+		if nestedPanelsJson := getPanelObjects(panelJson["panels"]); len(nestedPanelsJson) > 0 {
+			y := layout.y
+			panelJson["panels"] = layout.clonePanels(nestedPanelsJson, armResources, subResourceType, subResourceName, true)
+			layout.y = y
+		}
+	}
+
+	dashboard.ParsedJson["panels"] = newPanelsJson
+}
+
+func (layout *GrafanaPanelLayout) clonePanels(panelsJson []map[string]interface{}, armResources []ArmResource, subResourceType string, subResourceName string, hasGridPos bool) []interface{} {
+	newPanelsJson := make([]interface{}, 0)
+	for _, panelJson := range panelsJson {
+		clonesJson := make([]map[string]interface{}, 0)
+		if targetJson, ok := getTemplateTarget(panelJson); ok {
+			title, _ := panelJson["title"].(string)
+			for _, armResource := range armResources {
+				cloneJson := copyMap(panelJson)
+				cloneJson["title"] = armResource.getResourceName()
+				if len(title) > 0 {
+					cloneJson["title"] = title + " - " + armResource.getResourceName()
+				}
+				cloneJson["targets"] = []interface{}{newAzureMonitorTarget(targetJson, armResource, subResourceType, subResourceName)}
+				clonesJson = append(clonesJson, cloneJson)
+			}
+		} else {
+			// Panels without Azure Monitor targets, e.g. text panels, are kept as-is
+			clonesJson = append(clonesJson, copyMap(panelJson))
+		}
+
+		width, height := getPanelSize(panelJson)
+		panelsPerLine := GrafanaGridWidth / width
+		for index, cloneJson := range clonesJson {
+			cloneJson["id"] = layout.nextId
+			layout.nextId++
+
+			if hasGridPos {
+				cloneJson["gridPos"] = map[string]interface{}{
+					"x": (index % panelsPerLine) * width,
+					"y": layout.y + (index/panelsPerLine)*height,
+					"w": width,
+					"h": height,
+				}
+			}
+
+			newPanelsJson = append(newPanelsJson, cloneJson)
+		}
+
+		layout.y += ((len(clonesJson) + panelsPerLine - 1) / panelsPerLine) * height
+	}
+
+	return newPanelsJson
+}
+
+// Get the width and height of the panel on the grid.  Panels of the rows schema have no gridPos and default to the
+// Grafana default size.
+func getPanelSize(panelJson map[string]interface{}) (int, int) {
+	width, height := 12, 8
+	if gridPosJson, ok := panelJson["gridPos"].(map[string]interface{}); ok {
+		if w, ok := gridPosJson["w"].(float64); ok && w > 0 && int(w) <= GrafanaGridWidth {
+			width = int(w)
+		}
+
+		if h, ok := gridPosJson["h"].(float64); ok && h > 0 {
+			height = int(h)
+		}
+	}
+
+	return width, height
+}
+
+// Check that the template supports the layout.  The repeat layout queries the resource by resourceUri, which the Azure
+// Monitor data source of older Grafana versions, including every template of the rows schema, does not understand.
+func (dashboard *GrafanaDashboard) checkLayout(layout string) error {
+	if layout != RepeatGrafanaLayout {
+		return nil
+	}
+
+	schemaVersion, _ := dashboard.ParsedJson["schemaVersion"].(float64)
+	if dashboard.isRowsSchema() || schemaVersion < GrafanaResourceUriSchemaVersion {
+		return fmt.Errorf("--layout %s needs a template of Grafana 9 or later (schemaVersion %d), whose Azure Monitor data source supports resourceUri, but the template has schemaVersion %v.  Use --layout %s or %s instead.",
+			RepeatGrafanaLayout, GrafanaResourceUriSchemaVersion, schemaVersion, TargetsGrafanaLayout, PanelsGrafanaLayout)
+	}
+
+	return nil
+}
+
+// Add a resource template variable holding the ARM resource IDs and repeat each template panel for each selected
+// resource.  The targets query the resource by its URI, since the variable cannot hold the resource group and name
+// separately.
+func (dashboard *GrafanaDashboard) repeatPanels(armResources []ArmResource, subResourceType string, subResourceName string) {
+	resourceIds := make([]string, 0)
+	for _, armResource := range armResources {
+		resourceIds = append(resourceIds, getMetricsResourceId(armResource, subResourceType, subResourceName))
+	}
+
+	dashboard.addTemplateVariable(newGrafanaCustomVariable(GrafanaResourceVariableName, "Resource", resourceIds))
+
+	for _, panelJson := range dashboard.getPanels() {
+		targetJson, ok := getTemplateTarget(panelJson)
+		if !ok {
+			continue
+		}
+
+		newAzureMonitorTargetJson := copyMap(targetJson["azureMonitor"].(map[string]interface{}))
+		delete(newAzureMonitorTargetJson, "resourceGroup")
+		delete(newAzureMonitorTargetJson, "resourceName")
+
+		// The resources of Grafana 10 templates take precedence over resourceUri
+		delete(newAzureMonitorTargetJson, "resources")
+		newAzureMonitorTargetJson["resourceUri"] = "$" + GrafanaResourceVariableName
+
+		newTargetJson := copyMap(targetJson)
+		newTargetJson["azureMonitor"] = newAzureMonitorTargetJson
+
+		panelJson["targets"] = []interface{}{newTargetJson}
+		panelJson["repeat"] = GrafanaResourceVariableName
+		panelJson["repeatDirection"] = "h"
+	}
+}
+
+// The ID of the resource the metrics are queried for, including the sub-resource if any
+func getMetricsResourceId(armResource ArmResource, subResourceType string, subResourceName string) string {
+	resourceId := armResource.Id
+	if len(subResourceType) > 0 && len(subResourceName) > 0 {
+		resourceId += "/" + subResourceType + "/" + subResourceName
+	}
+
+	return resourceId
+}
+
+func (dashboard *GrafanaDashboard) addTemplateVariable(variableJson map[string]interface{}) {
+	templatingJson, ok := dashboard.ParsedJson["templating"].(map[string]interface{})
+	if !ok {
+		templatingJson = make(map[string]interface{})
+		dashboard.ParsedJson["templating"] = templatingJson
+	}
+
+	listJson, _ := templatingJson["list"].([]interface{})
+	templatingJson["list"] = append(listJson, variableJson)
+}
+
+// Create a multi-value custom template variable with all values selected
+func newGrafanaCustomVariable(name string, label string, values []string) map[string]interface{} {
+	optionsJson := []interface{}{
+		map[string]interface{}{"text": "All", "value": "$__all", "selected": true},
+	}
+
+	for _, value := range values {
+		optionsJson = append(optionsJson, map[string]interface{}{"text": value, "value": value, "selected": false})
+	}
+
+	return map[string]interface{}{
+		"name":       name,
+		"label":      label,
+		"type":       "custom",
+		"query":      strings.Join(values, ","),
+		"multi":      true,
+		"includeAll": true,
+		"hide":       0,
+		"current":    map[string]interface{}{"text": "All", "value": []string{"$__all"}},
+		"options":    optionsJson,
+	}
+}
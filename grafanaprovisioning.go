@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	AzureMonitorGrafanaDataSourceType = "grafana-azure-monitor-datasource"
+
+	// The client secret is read from this environment variable of Grafana rather than written to the provisioning file
+	AzureClientSecretEnvironmentVariable = "AZURE_CLIENT_SECRET"
+)
+
+type GrafanaDashboardProviderFile struct {
+	ApiVersion int                        `yaml:"apiVersion"`
+	Providers  []GrafanaDashboardProvider `yaml:"providers"`
+}
+
+type GrafanaDashboardProvider struct {
+	Name                  string                          `yaml:"name"`
+	OrgId                 int                             `yaml:"orgId"`
+	Folder                string                          `yaml:"folder"`
+	Type                  string                          `yaml:"type"`
+	DisableDeletion       bool                            `yaml:"disableDeletion"`
+	UpdateIntervalSeconds int                             `yaml:"updateIntervalSeconds"`
+	AllowUiUpdates        bool                            `yaml:"allowUiUpdates"`
+	Options               GrafanaDashboardProviderOptions `yaml:"options"`
+}
+
+type GrafanaDashboardProviderOptions struct {
+	Path string `yaml:"path"`
+}
+
+type GrafanaDataSourceFile struct {
+	ApiVersion  int                 `yaml:"apiVersion"`
+	DataSources []GrafanaDataSource `yaml:"datasources"`
+}
+
+type GrafanaDataSource struct {
+	Name           string            `yaml:"name"`
+	Type           string            `yaml:"type"`
+	Access         string            `yaml:"access"`
+	JsonData       map[string]string `yaml:"jsonData"`
+	SecureJsonData map[string]string `yaml:"secureJsonData"`
+	Editable       bool              `yaml:"editable"`
+}
+
+// Provision the dashboards of the directory into the Grafana folder
+func newGrafanaDashboardProvider(folder string, path string) GrafanaDashboardProvider {
+	return GrafanaDashboardProvider{
+		Name:                  "armclient " + folder,
+		OrgId:                 1,
+		Folder:                folder,
+		Type:                  "file",
+		UpdateIntervalSeconds: 30,
+		Options: GrafanaDashboardProviderOptions{
+			Path: path,
+		},
+	}
+}
+
+// Provision the Azure Monitor data source with the service principal of the armclient config
+func newAzureMonitorGrafanaDataSource(name string, credentials AzureCredentials) GrafanaDataSource {
+	cloudName := "azuremonitor"
+	if strings.EqualFold(credentials.Environment, GermanEnvironmentName) {
+		cloudName = "germanyazuremonitor"
+	}
+
+	return GrafanaDataSource{
+		Name:   name,
+		Type:   AzureMonitorGrafanaDataSourceType,
+		Access: "proxy",
+		JsonData: map[string]string{
+			"cloudName":      cloudName,
+			"azureAuthType":  "clientsecret",
+			"tenantId":       credentials.TenantID,
+			"clientId":       credentials.ClientID,
+			"subscriptionId": credentials.SubscriptionID,
+		},
+		SecureJsonData: map[string]string{
+			"clientSecret": "${" + AzureClientSecretEnvironmentVariable + "}",
+		},
+		Editable: true,
+	}
+}
+
+func writeGrafanaProvisioningFile(provisioningFile string, value interface{}) {
+	contents, err := yaml.Marshal(value)
+	if err != nil {
+		log.Fatalf("Error generating provisioning file: %v", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(provisioningFile), 0755)
+	if err != nil {
+		log.Fatalf("Error creating provisioning directory: %v", err)
+	}
+
+	err = ioutil.WriteFile(provisioningFile, contents, 0644)
+	if err != nil {
+		log.Fatalf("Error writing provisioning file: %v", err)
+	}
+
+	fmt.Printf("Created %s\n", provisioningFile)
+}
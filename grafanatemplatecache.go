@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Describes the cached templates of a resource type
+	GrafanaTemplateCacheFileName = "cache.json"
+)
+
+var invalidGrafanaTemplateCacheDirChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Template sources that can be cached.  The cached templates of a resource type are used as long as the version of the
+// templates is unchanged.
+type CacheableGrafanaTemplateSource interface {
+	GrafanaTemplateSource
+
+	// Get the template directories of every resource type
+	getTemplateDirs() ([]string, error)
+
+	// Get the current version of the templates of the resource type, or empty if unknown
+	getTemplatesVersion(templateDir string) (string, error)
+}
+
+type GrafanaTemplateCacheEntry struct {
+	Source  string    `json:"source"`
+	Version string    `json:"version"`
+	Updated time.Time `json:"updated"`
+}
+
+// Stores the templates of each source in a folder with the same {type}/{dashboard}/template.json layout as a local
+// template directory
+type GrafanaTemplateCache struct {
+	rootDir string
+}
+
+// Reads the templates of a source through the cache, or only from the cache when offline
+type CachedGrafanaTemplateSource struct {
+	source    CacheableGrafanaTemplateSource
+	cache     *GrafanaTemplateCache
+	isOffline bool
+}
+
+// Create the template cache in the directory.  Default to armclient/templates in the user cache directory.
+func NewGrafanaTemplateCache(rootDir string) (*GrafanaTemplateCache, error) {
+	if len(rootDir) == 0 {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("Unable to find the user cache directory, use --cache-dir: %v", err)
+		}
+
+		rootDir = filepath.Join(userCacheDir, "armclient", "templates")
+	}
+
+	return &GrafanaTemplateCache{rootDir: rootDir}, nil
+}
+
+func NewCachedGrafanaTemplateSource(source CacheableGrafanaTemplateSource, cache *GrafanaTemplateCache, isOffline bool) *CachedGrafanaTemplateSource {
+	return &CachedGrafanaTemplateSource{
+		source:    source,
+		cache:     cache,
+		isOffline: isOffline,
+	}
+}
+
+// Get the cache folder of the source.  The source name includes the ref, e.g. github:owner/repo@ref.
+func (cache *GrafanaTemplateCache) getSourceDir(source GrafanaTemplateSource) string {
+	return filepath.Join(cache.rootDir, invalidGrafanaTemplateCacheDirChars.ReplaceAllString(source.String(), "_"))
+}
+
+// Join the template directory and dashboard names to the cache folder of the source.  The names come from the source, so
+// the path is checked to stay inside the cache folder.
+func (cache *GrafanaTemplateCache) getTemplatePath(source GrafanaTemplateSource, names ...string) (string, error) {
+	sourceDir := cache.getSourceDir(source)
+	for _, name := range names {
+		err := validateGrafanaTemplateName(name)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	templatePath := filepath.Join(append([]string{sourceDir}, names...)...)
+	relativePath, err := filepath.Rel(sourceDir, templatePath)
+	if err != nil || relativePath == "." || relativePath == ".." || strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("Template path %s is outside of the template cache %s", templatePath, sourceDir)
+	}
+
+	return templatePath, nil
+}
+
+func (cache *GrafanaTemplateCache) getEntry(source GrafanaTemplateSource, templateDir string) (GrafanaTemplateCacheEntry, bool) {
+	var entry GrafanaTemplateCacheEntry
+	dir, err := cache.getTemplatePath(source, templateDir)
+	if err != nil {
+		return entry, false
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, GrafanaTemplateCacheFileName))
+	if err != nil {
+		return entry, false
+	}
+
+	err = json.Unmarshal(contents, &entry)
+	if err != nil {
+		log.Warnf("Ignoring invalid template cache file of %s: %v", templateDir, err)
+		return entry, false
+	}
+
+	return entry, true
+}
+
+func (cache *GrafanaTemplateCache) getTemplates(source GrafanaTemplateSource, templateDir string) ([]GrafanaDashboardTemplate, error) {
+	localSource := &LocalGrafanaTemplateSource{rootDir: cache.getSourceDir(source)}
+	return localSource.getTemplates(templateDir)
+}
+
+// Replace the cached templates of the resource type
+func (cache *GrafanaTemplateCache) saveTemplates(source GrafanaTemplateSource, templateDir string, version string, dashboardTemplates []GrafanaDashboardTemplate) error {
+	dir, err := cache.getTemplatePath(source, templateDir)
+	if err != nil {
+		return err
+	}
+
+	// Check every path before removing the cached templates
+	dashboardDirs := make([]string, 0)
+	for _, dashboardTemplate := range dashboardTemplates {
+		dashboardDir, err := cache.getTemplatePath(source, templateDir, dashboardTemplate.Name)
+		if err != nil {
+			return err
+		}
+
+		dashboardDirs = append(dashboardDirs, dashboardDir)
+	}
+
+	err = os.RemoveAll(dir)
+	if err != nil {
+		return err
+	}
+
+	for index, dashboardTemplate := range dashboardTemplates {
+		dashboardDir := dashboardDirs[index]
+		err = os.MkdirAll(dashboardDir, 0755)
+		if err != nil {
+			return err
+		}
+
+		err = ioutil.WriteFile(filepath.Join(dashboardDir, GrafanaTemplateFileName), []byte(dashboardTemplate.Contents), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Resource types without templates are cached too, so they are not looked up again
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	entry := GrafanaTemplateCacheEntry{
+		Source:  source.String(),
+		Version: version,
+		Updated: time.Now().UTC(),
+	}
+
+	contents, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, GrafanaTemplateCacheFileName), contents, 0644)
+}
+
+func (cachedSource *CachedGrafanaTemplateSource) String() string {
+	return cachedSource.source.String()
+}
+
+func (cachedSource *CachedGrafanaTemplateSource) getTemplates(templateDir string) ([]GrafanaDashboardTemplate, error) {
+	err := validateGrafanaTemplateName(templateDir)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, isCached := cachedSource.cache.getEntry(cachedSource.source, templateDir)
+	if cachedSource.isOffline {
+		if !isCached {
+			return nil, fmt.Errorf("No cached templates for %s, run armclient templates sync first", templateDir)
+		}
+
+		return cachedSource.cache.getTemplates(cachedSource.source, templateDir)
+	}
+
+	version, err := cachedSource.source.getTemplatesVersion(templateDir)
+	if err != nil {
+		if !isCached {
+			return nil, err
+		}
+
+		log.Warnf("Using cached templates of %s from %s: %v", templateDir, entry.Updated.Format(time.RFC3339), err)
+		return cachedSource.cache.getTemplates(cachedSource.source, templateDir)
+	}
+
+	if isCached && len(version) > 0 && version == entry.Version {
+		log.Debugf("Using cached templates of %s at version %s", templateDir, version)
+		return cachedSource.cache.getTemplates(cachedSource.source, templateDir)
+	}
+
+	dashboardTemplates, err := cachedSource.source.getTemplates(templateDir)
+	if err != nil {
+		if !isCached {
+			return nil, err
+		}
+
+		log.Warnf("Using cached templates of %s from %s: %v", templateDir, entry.Updated.Format(time.RFC3339), err)
+		return cachedSource.cache.getTemplates(cachedSource.source, templateDir)
+	}
+
+	err = cachedSource.cache.saveTemplates(cachedSource.source, templateDir, version, dashboardTemplates)
+	if err != nil {
+		log.Warnf("Unable to cache the templates of %s: %v", templateDir, err)
+	}
+
+	return dashboardTemplates, nil
+}
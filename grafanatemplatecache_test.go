@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestValidateGrafanaTemplateName(t *testing.T) {
+	tests := []struct {
+		name          string
+		expectedError bool
+	}{
+		{"Microsoft-Storage-storageAccounts", false},
+		{"transactions", false},
+		{"..transactions", false},
+		{"", true},
+		{".", true},
+		{"..", true},
+		{"../transactions", true},
+		{"a/b", true},
+		{`a\b`, true},
+		{"/etc", true},
+	}
+
+	for _, test := range tests {
+		if err := validateGrafanaTemplateName(test.name); (err != nil) != test.expectedError {
+			t.Errorf("%q: expected error %t, got %v", test.name, test.expectedError, err)
+		}
+	}
+}
+
+func TestGrafanaTemplateCacheSaveTemplates(t *testing.T) {
+	rootDir := t.TempDir()
+	cache, _ := NewGrafanaTemplateCache(filepath.Join(rootDir, "cache"))
+	source := &HttpGrafanaTemplateSource{baseUrl: "https://example.com/templates"}
+
+	// A file next to the cache that a traversing name would remove or overwrite
+	outsideFile := filepath.Join(rootDir, "outside.json")
+	ioutil.WriteFile(outsideFile, []byte("outside"), 0644)
+
+	err := cache.saveTemplates(source, "Microsoft-Web-sites", "v1", []GrafanaDashboardTemplate{{Name: "requests", Contents: "{}"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		templateDir        string
+		dashboardTemplates []GrafanaDashboardTemplate
+	}{
+		{"..", nil},
+		{"../..", nil},
+		{".", nil},
+		{"", nil},
+		{"Microsoft-Web-sites", []GrafanaDashboardTemplate{{Name: "..", Contents: "{}"}}},
+		{"Microsoft-Web-sites", []GrafanaDashboardTemplate{{Name: "../../../outside", Contents: "{}"}}},
+		{"Microsoft-Web-sites", []GrafanaDashboardTemplate{{Name: "ok", Contents: "{}"}, {Name: "a/b", Contents: "{}"}}},
+	}
+
+	for _, test := range tests {
+		if err := cache.saveTemplates(source, test.templateDir, "v2", test.dashboardTemplates); err == nil {
+			t.Errorf("%q, %v: expected error", test.templateDir, test.dashboardTemplates)
+		}
+	}
+
+	// Nothing was removed or written by the rejected saves
+	if contents, err := ioutil.ReadFile(outsideFile); err != nil || string(contents) != "outside" {
+		t.Errorf("file outside of the cache changed: %s, %v", contents, err)
+	}
+
+	entry, ok := cache.getEntry(source, "Microsoft-Web-sites")
+	dashboardTemplates, err := cache.getTemplates(source, "Microsoft-Web-sites")
+	if !ok || entry.Version != "v1" || err != nil || !reflect.DeepEqual(dashboardTemplates, []GrafanaDashboardTemplate{{Name: "requests", Contents: "{}"}}) {
+		t.Errorf("cached templates changed: %+v, %v, %v", entry, dashboardTemplates, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(cache.getSourceDir(source), "Microsoft-Web-sites", "ok")); !os.IsNotExist(err) {
+		t.Errorf("template written before every name was checked")
+	}
+}
+
+func TestArchiveGrafanaTemplateSourceRejectsTraversal(t *testing.T) {
+	source := &ArchiveGrafanaTemplateSource{
+		location: "templates.zip",
+		files: map[string][]byte{
+			"templates-master/Microsoft-Web-sites/../template.json": []byte("{}"),
+			"templates-master/../requests/template.json":            []byte("{}"),
+		},
+	}
+
+	if _, err := source.getTemplates("Microsoft-Web-sites"); err == nil {
+		t.Errorf("expected error for dashboard name ..")
+	}
+
+	if _, err := source.getTemplateDirs(); err == nil {
+		t.Errorf("expected error for template directory ..")
+	}
+}
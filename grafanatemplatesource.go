@@ -0,0 +1,477 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Every template source has a {type}/{dashboard}/template.json layout
+	GrafanaTemplateFileName = "template.json"
+
+	// Lists the dashboard folders of a type for HTTPS template sources, which cannot list directories
+	GrafanaTemplateIndexFileName = "index.json"
+)
+
+type GrafanaDashboardTemplate struct {
+	Name     string
+	Contents string
+}
+
+// Source of the Grafana dashboard templates of each resource type
+type GrafanaTemplateSource interface {
+	// Get the templates in the directory of the resource type
+	getTemplates(templateDir string) ([]GrafanaDashboardTemplate, error)
+	String() string
+}
+
+// Reads the templates from a local directory
+type LocalGrafanaTemplateSource struct {
+	rootDir string
+}
+
+// Reads the templates from an HTTPS base URL.  The directory of each type has an index.json listing its dashboards.
+type HttpGrafanaTemplateSource struct {
+	baseUrl string
+}
+
+// Reads the templates from a zip file or tarball, either local or at an HTTPS URL.  The layout may be nested in a
+// top-level directory, as in the archives of GitHub repositories.
+type ArchiveGrafanaTemplateSource struct {
+	location string
+	files    map[string][]byte
+}
+
+// Create the template source from its specification: github:{owner}/{repo}[@{ref}], the path or HTTPS URL of a
+// .zip, .tar.gz or .tgz file, an HTTPS base URL or a local directory.  Default to the public template repository.
+func NewGrafanaTemplateSource(specification string) (GrafanaTemplateSource, error) {
+	lowerSpecification := strings.ToLower(specification)
+	switch {
+	case strings.HasPrefix(lowerSpecification, "http://"):
+		return nil, fmt.Errorf("Template source %s must use HTTPS", specification)
+	case len(specification) == 0:
+		return NewGitHubGrafanaTemplateSource(DefaultGitHubGrafanaTemplateOwner, DefaultGitHubGrafanaTemplateRepo, DefaultGitHubGrafanaTemplateRef), nil
+	case strings.HasPrefix(lowerSpecification, "github:"):
+		repository, ref := strings.TrimPrefix(specification[len("github:"):], "/"), DefaultGitHubGrafanaTemplateRef
+		if index := strings.LastIndex(repository, "@"); index >= 0 {
+			repository, ref = repository[:index], repository[index+1:]
+		}
+
+		parts := strings.Split(repository, "/")
+		if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 || len(ref) == 0 {
+			return nil, fmt.Errorf("Invalid GitHub template source %s, expected github:{owner}/{repo}[@{ref}]", specification)
+		}
+
+		return NewGitHubGrafanaTemplateSource(parts[0], parts[1], ref), nil
+	case isArchiveFile(lowerSpecification):
+		return &ArchiveGrafanaTemplateSource{location: specification}, nil
+	case isHttpsUrl(lowerSpecification):
+		return &HttpGrafanaTemplateSource{baseUrl: strings.TrimSuffix(specification, "/")}, nil
+	}
+
+	info, err := os.Stat(specification)
+	if err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("Template directory %s does not exist", specification)
+	}
+
+	return &LocalGrafanaTemplateSource{rootDir: specification}, nil
+}
+
+// Get the template directory of the resource type, e.g. Microsoft-Storage-storageAccounts-blobServices.  The kind is
+// not part of the directory.
+func getGrafanaTemplateDir(resourceType string, subResourceType string) string {
+	templateDir := resourceType
+	if len(subResourceType) > 0 {
+		templateDir += "/" + subResourceType
+	}
+
+	templateDir = strings.Replace(templateDir, ".", "-", -1)
+	templateDir = strings.Replace(templateDir, "/", "-", -1)
+	return templateDir
+}
+
+// Check a template directory or dashboard name read from a template source.  The names are joined to local paths, so
+// each must be a single path element.
+func validateGrafanaTemplateName(name string) error {
+	if len(name) == 0 || name == "." || name == ".." || strings.ContainsAny(name, `/\`) || strings.ContainsRune(name, filepath.Separator) {
+		return fmt.Errorf("Invalid template name %q", name)
+	}
+
+	return nil
+}
+
+// The templates are uploaded to Grafana, so remote template sources must use HTTPS
+func isHttpsUrl(location string) bool {
+	return strings.HasPrefix(strings.ToLower(location), "https://")
+}
+
+func isArchiveFile(location string) bool {
+	location = strings.Split(location, "?")[0]
+	return strings.HasSuffix(location, ".zip") || strings.HasSuffix(location, ".tar.gz") || strings.HasSuffix(location, ".tgz")
+}
+
+func (source *LocalGrafanaTemplateSource) String() string {
+	return source.rootDir
+}
+
+func (source *LocalGrafanaTemplateSource) getTemplates(templateDir string) ([]GrafanaDashboardTemplate, error) {
+	dashboardTemplates := make([]GrafanaDashboardTemplate, 0)
+
+	dashboardDirs, err := ioutil.ReadDir(filepath.Join(source.rootDir, templateDir))
+	if os.IsNotExist(err) {
+		return dashboardTemplates, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dashboardDir := range dashboardDirs {
+		if !dashboardDir.IsDir() {
+			continue
+		}
+
+		contents, err := ioutil.ReadFile(filepath.Join(source.rootDir, templateDir, dashboardDir.Name(), GrafanaTemplateFileName))
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		dashboardTemplates = append(dashboardTemplates, GrafanaDashboardTemplate{Name: dashboardDir.Name(), Contents: string(contents)})
+	}
+
+	return dashboardTemplates, nil
+}
+
+func (source *HttpGrafanaTemplateSource) String() string {
+	return source.baseUrl
+}
+
+func (source *HttpGrafanaTemplateSource) getTemplates(templateDir string) ([]GrafanaDashboardTemplate, error) {
+	dashboardTemplates := make([]GrafanaDashboardTemplate, 0)
+
+	index, found, err := httpGetFile(source.baseUrl + "/" + templateDir + "/" + GrafanaTemplateIndexFileName)
+	if err != nil || !found {
+		return dashboardTemplates, err
+	}
+
+	var dashboardNames []string
+	err = json.Unmarshal(index, &dashboardNames)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing %s of %s: %v", GrafanaTemplateIndexFileName, templateDir, err)
+	}
+
+	for _, dashboardName := range dashboardNames {
+		err := validateGrafanaTemplateName(dashboardName)
+		if err != nil {
+			return nil, fmt.Errorf("Error in %s of %s: %v", GrafanaTemplateIndexFileName, templateDir, err)
+		}
+
+		contents, found, err := httpGetFile(source.baseUrl + "/" + templateDir + "/" + dashboardName + "/" + GrafanaTemplateFileName)
+		if err != nil {
+			return nil, err
+		}
+
+		if !found {
+			log.Warnf("Template %s of %s not found", dashboardName, templateDir)
+			continue
+		}
+
+		dashboardTemplates = append(dashboardTemplates, GrafanaDashboardTemplate{Name: dashboardName, Contents: string(contents)})
+	}
+
+	return dashboardTemplates, nil
+}
+
+// Get the folders of the resource types from the index.json at the base URL
+func (source *HttpGrafanaTemplateSource) getTemplateDirs() ([]string, error) {
+	index, found, err := httpGetFile(source.baseUrl + "/" + GrafanaTemplateIndexFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, fmt.Errorf("%s/%s not found, it must list the folders of the resource types", source.baseUrl, GrafanaTemplateIndexFileName)
+	}
+
+	var templateDirs []string
+	err = json.Unmarshal(index, &templateDirs)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing %s: %v", GrafanaTemplateIndexFileName, err)
+	}
+
+	for _, templateDir := range templateDirs {
+		err = validateGrafanaTemplateName(templateDir)
+		if err != nil {
+			return nil, fmt.Errorf("Error in %s: %v", GrafanaTemplateIndexFileName, err)
+		}
+	}
+
+	return templateDirs, nil
+}
+
+// The templates of the resource type are versioned by the ETags of its index.json and templates.  Returns empty if the
+// server does not send ETags, so the templates are always read again.
+func (source *HttpGrafanaTemplateSource) getTemplatesVersion(templateDir string) (string, error) {
+	index, etag, found, err := httpRequestFile("GET", source.baseUrl+"/"+templateDir+"/"+GrafanaTemplateIndexFileName)
+	if err != nil || !found || len(etag) == 0 {
+		return "", err
+	}
+
+	var dashboardNames []string
+	err = json.Unmarshal(index, &dashboardNames)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing %s of %s: %v", GrafanaTemplateIndexFileName, templateDir, err)
+	}
+
+	etags := []string{etag}
+	for _, dashboardName := range dashboardNames {
+		_, etag, _, err := httpRequestFile("HEAD", source.baseUrl+"/"+templateDir+"/"+dashboardName+"/"+GrafanaTemplateFileName)
+		if err != nil || len(etag) == 0 {
+			return "", err
+		}
+
+		etags = append(etags, etag)
+	}
+
+	return fmt.Sprintf("%x", sha1.Sum([]byte(strings.Join(etags, "|")))), nil
+}
+
+func (source *ArchiveGrafanaTemplateSource) String() string {
+	return source.location
+}
+
+func (source *ArchiveGrafanaTemplateSource) getTemplates(templateDir string) ([]GrafanaDashboardTemplate, error) {
+	err := source.ensureFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	dashboardTemplates := make([]GrafanaDashboardTemplate, 0)
+	for filePath, contents := range source.files {
+		// Match {type}/{dashboard}/template.json at any depth
+		parts := strings.Split(filePath, "/")
+		if len(parts) < 3 || !strings.EqualFold(parts[len(parts)-1], GrafanaTemplateFileName) || !strings.EqualFold(parts[len(parts)-3], templateDir) {
+			continue
+		}
+
+		err := validateGrafanaTemplateName(parts[len(parts)-2])
+		if err != nil {
+			return nil, fmt.Errorf("Error in template archive %s: %v", source.location, err)
+		}
+
+		dashboardTemplates = append(dashboardTemplates, GrafanaDashboardTemplate{Name: parts[len(parts)-2], Contents: string(contents)})
+	}
+
+	sort.Slice(dashboardTemplates, func(i, j int) bool {
+		return dashboardTemplates[i].Name < dashboardTemplates[j].Name
+	})
+
+	return dashboardTemplates, nil
+}
+
+// Get the folders of the resource types that contain templates
+func (source *ArchiveGrafanaTemplateSource) getTemplateDirs() ([]string, error) {
+	err := source.ensureFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	templateDirs := make([]string, 0)
+	for filePath := range source.files {
+		parts := strings.Split(filePath, "/")
+		if len(parts) < 3 || containsIgnoreCase(templateDirs, parts[len(parts)-3]) {
+			continue
+		}
+
+		err := validateGrafanaTemplateName(parts[len(parts)-3])
+		if err != nil {
+			return nil, fmt.Errorf("Error in template archive %s: %v", source.location, err)
+		}
+
+		templateDirs = append(templateDirs, parts[len(parts)-3])
+	}
+
+	sort.Strings(templateDirs)
+	return templateDirs, nil
+}
+
+// The templates of every resource type are versioned by the ETag of a remote archive, or the modification time and
+// size of a local archive
+func (source *ArchiveGrafanaTemplateSource) getTemplatesVersion(templateDir string) (string, error) {
+	if isHttpsUrl(source.location) {
+		_, etag, found, err := httpRequestFile("HEAD", source.location)
+		if err == nil && !found {
+			err = fmt.Errorf("Template archive %s not found", source.location)
+		}
+
+		return etag, err
+	}
+
+	info, err := os.Stat(source.location)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size()), nil
+}
+
+// Read the archive once for all resource types
+func (source *ArchiveGrafanaTemplateSource) ensureFiles() error {
+	if source.files != nil {
+		return nil
+	}
+
+	files, err := source.readArchive()
+	if err != nil {
+		return fmt.Errorf("Error reading template archive %s: %v", source.location, err)
+	}
+
+	source.files = files
+	return nil
+}
+
+// Read the template files of the archive by path
+func (source *ArchiveGrafanaTemplateSource) readArchive() (map[string][]byte, error) {
+	var archive []byte
+	var err error
+	lowerLocation := strings.ToLower(source.location)
+	if isHttpsUrl(lowerLocation) {
+		var found bool
+		archive, found, err = httpGetFile(source.location)
+		if err == nil && !found {
+			err = fmt.Errorf("not found")
+		}
+	} else {
+		archive, err = ioutil.ReadFile(source.location)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if isArchiveFile(lowerLocation) && strings.HasSuffix(strings.Split(lowerLocation, "?")[0], ".zip") {
+		return readZipTemplateFiles(archive)
+	}
+
+	return readTarballTemplateFiles(archive)
+}
+
+func readZipTemplateFiles(archive []byte) (map[string][]byte, error) {
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte)
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() || !strings.EqualFold(path.Base(file.Name), GrafanaTemplateFileName) {
+			continue
+		}
+
+		fileReader, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+
+		contents, err := ioutil.ReadAll(fileReader)
+		fileReader.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		files[file.Name] = contents
+	}
+
+	return files, nil
+}
+
+func readTarballTemplateFiles(archive []byte) (map[string][]byte, error) {
+	gzipReader, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+
+	defer gzipReader.Close()
+
+	files := make(map[string][]byte)
+	reader := tar.NewReader(gzipReader)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if header.Typeflag != tar.TypeReg || !strings.EqualFold(path.Base(header.Name), GrafanaTemplateFileName) {
+			continue
+		}
+
+		contents, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		files[header.Name] = contents
+	}
+
+	return files, nil
+}
+
+// Get the file at the URL.  Returns false if the file does not exist.
+func httpGetFile(targetUrl string) ([]byte, bool, error) {
+	body, _, found, err := httpRequestFile("GET", targetUrl)
+	return body, found, err
+}
+
+// Send a GET or HEAD request for the file at the URL.  Returns the body, the ETag and false if the file does not exist.
+func httpRequestFile(method string, targetUrl string) ([]byte, string, bool, error) {
+	request, err := http.NewRequest(method, targetUrl, nil)
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	log.Debugf("Executing %s %s\n", method, targetUrl)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	log.Debugf("Status code: %d\n", response.StatusCode)
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return nil, "", false, nil
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, "", false, fmt.Errorf("%s %s failed with status code %d", method, targetUrl, response.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	return body, response.Header.Get("ETag"), true, nil
+}
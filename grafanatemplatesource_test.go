@@ -0,0 +1,250 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Template files of a repository archive, nested in a top-level directory like the archives of GitHub repositories
+var testGrafanaTemplateFiles = map[string]string{
+	"templates-master/Microsoft-Storage-storageAccounts/transactions/template.json": `{"title":"transactions"}`,
+	"templates-master/Microsoft-Storage-storageAccounts/capacity/template.json":     `{"title":"capacity"}`,
+	"templates-master/Microsoft-Storage-storageAccounts/capacity/README.md":         `capacity`,
+	"templates-master/Microsoft-Web-sites/requests/template.json":                   `{"title":"requests"}`,
+	"templates-master/README.md": `templates`,
+}
+
+func writeTestZipArchive(t *testing.T, archiveFile string) {
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	for name, contents := range testGrafanaTemplateFiles {
+		fileWriter, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fileWriter.Write([]byte(contents))
+	}
+
+	writer.Close()
+	if err := ioutil.WriteFile(archiveFile, archive.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTestTarballArchive(t *testing.T, archiveFile string) {
+	var archive bytes.Buffer
+	gzipWriter := gzip.NewWriter(&archive)
+	writer := tar.NewWriter(gzipWriter)
+	writer.WriteHeader(&tar.Header{Name: "templates-master/", Typeflag: tar.TypeDir, Mode: 0755})
+	for name, contents := range testGrafanaTemplateFiles {
+		writer.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(contents))})
+		writer.Write([]byte(contents))
+	}
+
+	writer.Close()
+	gzipWriter.Close()
+	if err := ioutil.WriteFile(archiveFile, archive.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func getTemplateNames(dashboardTemplates []GrafanaDashboardTemplate) []string {
+	names := make([]string, 0)
+	for _, dashboardTemplate := range dashboardTemplates {
+		names = append(names, dashboardTemplate.Name+"="+dashboardTemplate.Contents)
+	}
+
+	return names
+}
+
+func TestNewGrafanaTemplateSource(t *testing.T) {
+	localDir := t.TempDir()
+	localFile := filepath.Join(localDir, "file.json")
+	ioutil.WriteFile(localFile, []byte("{}"), 0644)
+
+	tests := []struct {
+		specification  string
+		expectedSource GrafanaTemplateSource
+	}{
+		{"", &GitHubGrafanaTemplateSource{owner: DefaultGitHubGrafanaTemplateOwner, repo: DefaultGitHubGrafanaTemplateRepo, ref: DefaultGitHubGrafanaTemplateRef, apiRootUrl: GitHubApiRootUrl, httpClient: &http.Client{}}},
+		{"github:contoso/templates", &GitHubGrafanaTemplateSource{owner: "contoso", repo: "templates", ref: DefaultGitHubGrafanaTemplateRef, apiRootUrl: GitHubApiRootUrl, httpClient: &http.Client{}}},
+		{"GitHub:/contoso/templates@v1.2", &GitHubGrafanaTemplateSource{owner: "contoso", repo: "templates", ref: "v1.2", apiRootUrl: GitHubApiRootUrl, httpClient: &http.Client{}}},
+		{"github:contoso", nil},
+		{"github:contoso/templates/extra", nil},
+		{"github:/templates", nil},
+		{"github:contoso/templates@", nil},
+		{"templates.zip", &ArchiveGrafanaTemplateSource{location: "templates.zip"}},
+		{"Templates.TAR.GZ", &ArchiveGrafanaTemplateSource{location: "Templates.TAR.GZ"}},
+		{"https://example.com/templates.tgz?token=x", &ArchiveGrafanaTemplateSource{location: "https://example.com/templates.tgz?token=x"}},
+		{"https://example.com/templates/", &HttpGrafanaTemplateSource{baseUrl: "https://example.com/templates"}},
+		{"HTTPS://example.com/templates", &HttpGrafanaTemplateSource{baseUrl: "HTTPS://example.com/templates"}},
+		{"http://example.com/templates", nil},
+		{"http://example.com/templates.zip", nil},
+		{localDir, &LocalGrafanaTemplateSource{rootDir: localDir}},
+		{localFile, nil},
+		{filepath.Join(localDir, "missing"), nil},
+	}
+
+	for _, test := range tests {
+		source, err := NewGrafanaTemplateSource(test.specification)
+		if gitHubSource, ok := source.(*GitHubGrafanaTemplateSource); ok {
+			gitHubSource.token = ""
+		}
+
+		if test.expectedSource == nil {
+			if err == nil {
+				t.Errorf("%q: expected error, got %#v", test.specification, source)
+			}
+		} else if err != nil || !reflect.DeepEqual(source, test.expectedSource) {
+			t.Errorf("%q: expected %#v, got %#v, %v", test.specification, test.expectedSource, source, err)
+		}
+	}
+}
+
+func TestGetGrafanaTemplateDir(t *testing.T) {
+	tests := []struct {
+		resourceType    string
+		subResourceType string
+		expected        string
+	}{
+		{"Microsoft.Storage/storageAccounts", "", "Microsoft-Storage-storageAccounts"},
+		{"Microsoft.Storage/storageAccounts", "blobServices", "Microsoft-Storage-storageAccounts-blobServices"},
+		{"Microsoft.Network/frontDoors", "", "Microsoft-Network-frontDoors"},
+	}
+
+	for _, test := range tests {
+		if templateDir := getGrafanaTemplateDir(test.resourceType, test.subResourceType); templateDir != test.expected {
+			t.Errorf("%s, %s: expected %s, got %s", test.resourceType, test.subResourceType, test.expected, templateDir)
+		}
+	}
+}
+
+func TestArchiveGrafanaTemplateSource(t *testing.T) {
+	archiveDir := t.TempDir()
+	archiveFiles := map[string]func(t *testing.T, archiveFile string){
+		"templates.zip":    writeTestZipArchive,
+		"templates.tar.gz": writeTestTarballArchive,
+		"templates.tgz":    writeTestTarballArchive,
+	}
+
+	for name, writeArchive := range archiveFiles {
+		archiveFile := filepath.Join(archiveDir, name)
+		writeArchive(t, archiveFile)
+
+		source, err := NewGrafanaTemplateSource(archiveFile)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		archiveSource := source.(*ArchiveGrafanaTemplateSource)
+		templateDirs, err := archiveSource.getTemplateDirs()
+		if err != nil || !reflect.DeepEqual(templateDirs, []string{"Microsoft-Storage-storageAccounts", "Microsoft-Web-sites"}) {
+			t.Errorf("%s: unexpected template dirs %v, %v", name, templateDirs, err)
+		}
+
+		dashboardTemplates, err := source.getTemplates("microsoft-storage-storageaccounts")
+		expectedNames := []string{`capacity={"title":"capacity"}`, `transactions={"title":"transactions"}`}
+		if err != nil || !reflect.DeepEqual(getTemplateNames(dashboardTemplates), expectedNames) {
+			t.Errorf("%s: expected templates %v, got %v, %v", name, expectedNames, getTemplateNames(dashboardTemplates), err)
+		}
+
+		dashboardTemplates, err = source.getTemplates("Microsoft-Compute-virtualMachines")
+		if err != nil || len(dashboardTemplates) != 0 {
+			t.Errorf("%s: expected no templates, got %v, %v", name, getTemplateNames(dashboardTemplates), err)
+		}
+	}
+
+	ioutil.WriteFile(filepath.Join(archiveDir, "corrupt.zip"), []byte("not a zip"), 0644)
+	for _, archiveFile := range []string{filepath.Join(archiveDir, "corrupt.zip"), filepath.Join(archiveDir, "missing.tgz")} {
+		if _, err := (&ArchiveGrafanaTemplateSource{location: archiveFile}).getTemplates("Microsoft-Web-sites"); err == nil {
+			t.Errorf("%s: expected error", archiveFile)
+		}
+	}
+}
+
+func TestLocalGrafanaTemplateSource(t *testing.T) {
+	rootDir := t.TempDir()
+	for name, contents := range testGrafanaTemplateFiles {
+		file := filepath.Join(rootDir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(file), 0755)
+		ioutil.WriteFile(file, []byte(contents), 0644)
+	}
+
+	os.MkdirAll(filepath.Join(rootDir, "templates-master", "Microsoft-Web-sites", "empty"), 0755)
+	source := &LocalGrafanaTemplateSource{rootDir: filepath.Join(rootDir, "templates-master")}
+
+	dashboardTemplates, err := source.getTemplates("Microsoft-Web-sites")
+	if err != nil || !reflect.DeepEqual(getTemplateNames(dashboardTemplates), []string{`requests={"title":"requests"}`}) {
+		t.Errorf("unexpected templates %v, %v", getTemplateNames(dashboardTemplates), err)
+	}
+
+	dashboardTemplates, err = source.getTemplates("Microsoft-Compute-virtualMachines")
+	if err != nil || len(dashboardTemplates) != 0 {
+		t.Errorf("expected no templates, got %v, %v", getTemplateNames(dashboardTemplates), err)
+	}
+}
+
+func TestHttpGrafanaTemplateSource(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/templates/index.json":
+			w.Write([]byte(`["Microsoft-Web-sites"]`))
+		case "/templates/Microsoft-Web-sites/index.json":
+			w.Write([]byte(`["requests","missing"]`))
+		case "/templates/Microsoft-Web-sites/requests/template.json":
+			w.Write([]byte(`{"title":"requests"}`))
+		case "/templates/Microsoft-Storage-storageAccounts/index.json":
+			w.Write([]byte(`not json`))
+		case "/templates/Microsoft-Sql-servers/index.json":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	// Trust the test server certificate
+	defaultClient := http.DefaultClient
+	defer func() { http.DefaultClient = defaultClient }()
+	http.DefaultClient = server.Client()
+
+	source, err := NewGrafanaTemplateSource(server.URL + "/templates/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	templateDirs, err := source.(*HttpGrafanaTemplateSource).getTemplateDirs()
+	if err != nil || !reflect.DeepEqual(templateDirs, []string{"Microsoft-Web-sites"}) {
+		t.Errorf("unexpected template dirs %v, %v", templateDirs, err)
+	}
+
+	tests := []struct {
+		templateDir   string
+		expectedNames []string
+		expectedError bool
+	}{
+		{"Microsoft-Web-sites", []string{`requests={"title":"requests"}`}, false},
+		{"Microsoft-Compute-virtualMachines", []string{}, false},
+		{"Microsoft-Storage-storageAccounts", nil, true},
+		{"Microsoft-Sql-servers", nil, true},
+	}
+
+	for _, test := range tests {
+		dashboardTemplates, err := source.getTemplates(test.templateDir)
+		if (err != nil) != test.expectedError {
+			t.Errorf("%s: expected error %t, got %v", test.templateDir, test.expectedError, err)
+		} else if !test.expectedError && !reflect.DeepEqual(getTemplateNames(dashboardTemplates), test.expectedNames) {
+			t.Errorf("%s: expected templates %v, got %v", test.templateDir, test.expectedNames, getTemplateNames(dashboardTemplates))
+		}
+	}
+}
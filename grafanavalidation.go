@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/ahmetb/go-linq"
+)
+
+// A problem found when validating a Grafana dashboard template target against the metric definitions of an Azure resource
+type GrafanaTargetMismatch struct {
+	Template   string `json:"template"`
+	Panel      string `json:"panel"`
+	ResourceId string `json:"resourceId"`
+	MetricName string `json:"metricName"`
+	Problem    string `json:"problem"`
+}
+
+// Validates Grafana dashboard template targets against the metric definitions API.  Metric definitions are cached
+// per resource and metric namespace since every template of a resource type queries the same definitions.
+type GrafanaTemplateValidator struct {
+	azureClient       *AzureClient
+	metricDefinitions map[string][]MetricDefinition
+	metricErrors      map[string]error
+}
+
+func NewGrafanaTemplateValidator(azureClient *AzureClient) *GrafanaTemplateValidator {
+	return &GrafanaTemplateValidator{
+		azureClient:       azureClient,
+		metricDefinitions: make(map[string][]MetricDefinition),
+		metricErrors:      make(map[string]error),
+	}
+}
+
+func (validator *GrafanaTemplateValidator) validate(templateName string, dashboard *GrafanaDashboard, resourceId string) []GrafanaTargetMismatch {
+	mismatches := make([]GrafanaTargetMismatch, 0)
+	for _, panelJson := range dashboard.getPanels() {
+		panelTitle, _ := panelJson["title"].(string)
+		for _, azureMonitorTargetJson := range getAzureMonitorTargets(panelJson) {
+			metricName := getStringValue(azureMonitorTargetJson, "metricName")
+			for _, problem := range validator.validateTarget(azureMonitorTargetJson, resourceId) {
+				mismatches = append(mismatches, GrafanaTargetMismatch{
+					Template:   templateName,
+					Panel:      panelTitle,
+					ResourceId: resourceId,
+					MetricName: metricName,
+					Problem:    problem,
+				})
+			}
+		}
+	}
+
+	return mismatches
+}
+
+func (validator *GrafanaTemplateValidator) validateTarget(azureMonitorTargetJson map[string]interface{}, resourceId string) []string {
+	problems := make([]string, 0)
+
+	metricName := getStringValue(azureMonitorTargetJson, "metricName")
+	if len(metricName) == 0 || isGrafanaVariable(metricName) {
+		return problems
+	}
+
+	metricNamespace := getStringValue(azureMonitorTargetJson, "metricNamespace")
+	if isGrafanaVariable(metricNamespace) {
+		metricNamespace = ""
+	}
+
+	metricDefinitions, err := validator.getMetricDefinitions(resourceId, metricNamespace)
+	if err != nil {
+		return append(problems, fmt.Sprintf("metric namespace '%s' is not available: %v", metricNamespace, err))
+	}
+
+	var matchingMetricDefinitions []MetricDefinition
+	From(metricDefinitions).WhereT(func(d MetricDefinition) bool {
+		return strings.EqualFold(d.Name.Value, metricName)
+	}).ToSlice(&matchingMetricDefinitions)
+
+	if len(matchingMetricDefinitions) == 0 {
+		return append(problems, fmt.Sprintf("metric '%s' does not exist", metricName))
+	}
+
+	metricDefinition := matchingMetricDefinitions[0]
+
+	if len(metricNamespace) > 0 && !strings.EqualFold(metricDefinition.Namespace, metricNamespace) {
+		problems = append(problems, fmt.Sprintf("metric namespace '%s' does not match '%s'", metricNamespace, metricDefinition.Namespace))
+	}
+
+	aggregation := getStringValue(azureMonitorTargetJson, "aggregation")
+	if len(aggregation) > 0 && !isGrafanaVariable(aggregation) && !containsIgnoreCase(metricDefinition.SupportedAggregationTypes, aggregation) {
+		problems = append(problems, fmt.Sprintf("aggregation '%s' is not supported, expected one of: %s", aggregation, strings.Join(metricDefinition.SupportedAggregationTypes, ", ")))
+	}
+
+	timeGrain := getStringValue(azureMonitorTargetJson, "timeGrain")
+	if len(timeGrain) > 0 && !strings.EqualFold(timeGrain, "auto") && !isGrafanaVariable(timeGrain) && !containsIgnoreCase(metricDefinition.getTimeGrains(), timeGrain) {
+		problems = append(problems, fmt.Sprintf("time grain '%s' is not supported, expected one of: %s", timeGrain, strings.Join(metricDefinition.getTimeGrains(), ", ")))
+	}
+
+	for _, dimension := range getTargetDimensions(azureMonitorTargetJson) {
+		if !containsIgnoreCase(metricDefinition.getDimensionNames(), dimension) {
+			problems = append(problems, fmt.Sprintf("dimension '%s' does not exist, expected one of: %s", dimension, strings.Join(metricDefinition.getDimensionNames(), ", ")))
+		}
+	}
+
+	return problems
+}
+
+func (validator *GrafanaTemplateValidator) getMetricDefinitions(resourceId string, metricNamespace string) ([]MetricDefinition, error) {
+	cacheKey := strings.ToLower(resourceId + "|" + metricNamespace)
+	if err, ok := validator.metricErrors[cacheKey]; ok {
+		return nil, err
+	}
+
+	metricDefinitions, ok := validator.metricDefinitions[cacheKey]
+	if !ok {
+		var err error
+		metricDefinitions, err = validator.azureClient.tryGetMetricDefinitions(resourceId, metricNamespace)
+		if err != nil {
+			validator.metricErrors[cacheKey] = err
+			return nil, err
+		}
+
+		validator.metricDefinitions[cacheKey] = metricDefinitions
+	}
+
+	return metricDefinitions, nil
+}
+
+// Get the dimensions of the target.  Older versions of the Azure Monitor datasource store a single "dimension",
+// newer versions store a list of "dimensionFilters".
+func getTargetDimensions(azureMonitorTargetJson map[string]interface{}) []string {
+	dimensions := make([]string, 0)
+
+	dimension := getStringValue(azureMonitorTargetJson, "dimension")
+	if len(dimension) > 0 && !strings.EqualFold(dimension, "none") && !isGrafanaVariable(dimension) {
+		dimensions = append(dimensions, dimension)
+	}
+
+	dimensionFiltersJson, _ := azureMonitorTargetJson["dimensionFilters"].([]interface{})
+	for _, dimensionFilterJsonObject := range dimensionFiltersJson {
+		dimensionFilterJson, _ := dimensionFilterJsonObject.(map[string]interface{})
+		dimension := getStringValue(dimensionFilterJson, "dimension")
+		if len(dimension) > 0 && !isGrafanaVariable(dimension) {
+			dimensions = append(dimensions, dimension)
+		}
+	}
+
+	return dimensions
+}
+
+func getGrafanaTargetMismatchesOutputTable(mismatches []GrafanaTargetMismatch) OutputTable {
+	table := OutputTable{
+		Headers: []string{"Template", "Panel", "ResourceId", "Metric", "Problem"},
+	}
+
+	for _, mismatch := range mismatches {
+		table.addRow(mismatch.Template, mismatch.Panel, mismatch.ResourceId, mismatch.MetricName, mismatch.Problem)
+	}
+
+	return table
+}
+
+func getStringValue(jsonObject map[string]interface{}, key string) string {
+	value, _ := jsonObject[key].(string)
+	return value
+}
+
+func isGrafanaVariable(value string) bool {
+	return strings.HasPrefix(value, "$")
+}
+
+func containsIgnoreCase(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Update the Grafana dashboard template to select the resource with subscription, resource group and resource template
+// variables instead of listing the resources.  The variables are queried from the Azure Monitor data source, so the
+// dashboard stays current as resources are added.
+func (dashboard *GrafanaDashboard) updateWithVariables(title string, dataSourceName string, subscriptionId string, resourceType string, subResourceType string, subResourceName string) {
+	dashboard.ParsedJson["title"] = title
+
+	subscriptionVariableJson := newGrafanaQueryVariable("subscription", "Subscription", dataSourceName, "Subscriptions()")
+	subscriptionVariableJson["current"] = map[string]interface{}{"text": subscriptionId, "value": subscriptionId}
+	dashboard.addTemplateVariable(subscriptionVariableJson)
+	dashboard.addTemplateVariable(newGrafanaQueryVariable("resourceGroup", "Resource group", dataSourceName, "ResourceGroups($subscription)"))
+	dashboard.addTemplateVariable(newGrafanaQueryVariable(GrafanaResourceVariableName, "Resource", dataSourceName, fmt.Sprintf("ResourceNames($subscription, $resourceGroup, %s)", resourceType)))
+
+	// Sub-resources such as blobServices are not tracked by ARM, see newAzureMonitorTarget
+	resourceName := "$" + GrafanaResourceVariableName
+	resourceUri := fmt.Sprintf("/subscriptions/$subscription/resourceGroups/$resourceGroup/providers/%s/$%s", resourceType, GrafanaResourceVariableName)
+	if len(subResourceName) > 0 {
+		resourceName += "/" + subResourceName
+	}
+
+	if len(subResourceType) > 0 && len(subResourceName) > 0 {
+		resourceUri += "/" + subResourceType + "/" + subResourceName
+	}
+
+	dashboard.setDataSource(dataSourceName)
+	for _, panelJson := range dashboard.getPanels() {
+		targetJson, ok := getTemplateTarget(panelJson)
+		if !ok {
+			continue
+		}
+
+		newTargetJson := newAzureMonitorResourceTarget(targetJson, "$subscription", "$resourceGroup", resourceName, resourceUri, "")
+		newTargetJson["subscription"] = "$subscription"
+
+		panelJson["targets"] = []interface{}{newTargetJson}
+	}
+}
+
+// Create a single-value template variable queried from the data source on dashboard load
+func newGrafanaQueryVariable(name string, label string, dataSourceName string, query string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":       name,
+		"label":      label,
+		"type":       "query",
+		"datasource": dataSourceName,
+		"query":      query,
+		"refresh":    1,
+		"sort":       1,
+		"multi":      false,
+		"includeAll": false,
+		"hide":       0,
+		"current":    map[string]interface{}{},
+		"options":    []interface{}{},
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Destination of the generated Grafana dashboards
+type GrafanaDashboardWriter interface {
+	write(dashboard *GrafanaDashboard, file GrafanaDashboardFile)
+
+	// Called after all dashboards are written
+	close()
+}
+
+// Where a generated dashboard is written to
+type GrafanaDashboardFile struct {
+	// The encoded resource type and group of the dashboard, e.g. its region, which determine its folder.  The group is
+	// empty for dashboards that are not generated per group.
+	ResourceType string
+	Group        string
+
+	// The file name without extension
+	Name string
+}
+
+// Writes the dashboards as JSON files to the current directory, or to a {resource type}/{group} folder hierarchy
+// underneath the output directory
+type FileGrafanaDashboardWriter struct {
+	outputDir                       string
+	isProvisioningEnabled           bool
+	isDataSourceProvisioningEnabled bool
+	provisioningPath                string
+	dataSourceName                  string
+	credentials                     AzureCredentials
+
+	// The Grafana folder title of each dashboard directory
+	folders map[string]string
+}
+
+// Uploads the dashboards to Grafana, overwriting the existing dashboards
+type ApiGrafanaDashboardWriter struct {
+	grafanaClient *GrafanaClient
+	folder        GrafanaFolder
+	message       string
+	createdCount  int
+	updatedCount  int
+	failedCount   int
+}
+
+func NewGrafanaDashboardWriter(options GrafanaGenerateOptions, credentials AzureCredentials) GrafanaDashboardWriter {
+	if len(options.GrafanaUrl) == 0 {
+		if len(options.OutputDir) == 0 && (options.IsProvisioningEnabled || options.IsDataSourceProvisioningEnabled) {
+			log.Fatalf("Provisioning requires an output directory")
+		}
+
+		// Grafana does not resolve the provider paths relative to the working directory of armclient
+		provisioningPath := options.ProvisioningPath
+		if len(provisioningPath) == 0 {
+			var err error
+			provisioningPath, err = filepath.Abs(options.OutputDir)
+			if err != nil {
+				log.Fatalf("Error getting output directory path: %v", err)
+			}
+		}
+
+		return &FileGrafanaDashboardWriter{
+			outputDir:                       options.OutputDir,
+			isProvisioningEnabled:           options.IsProvisioningEnabled,
+			isDataSourceProvisioningEnabled: options.IsDataSourceProvisioningEnabled,
+			provisioningPath:                provisioningPath,
+			dataSourceName:                  options.DataSourceName,
+			credentials:                     credentials,
+			folders:                         make(map[string]string),
+		}
+	}
+
+	writer := &ApiGrafanaDashboardWriter{
+		grafanaClient: NewGrafanaClient(options.GrafanaUrl, options.GrafanaToken),
+		message:       options.GrafanaMessage,
+	}
+
+	// Without folder, the dashboards are saved to the General folder
+	if len(options.GrafanaFolder) > 0 {
+		folder, err := writer.grafanaClient.ensureFolder(options.GrafanaFolder)
+		if err != nil {
+			log.Fatalf("Error selecting Grafana folder: %v", err)
+		}
+
+		writer.folder = folder
+	}
+
+	return writer
+}
+
+func newGrafanaDashboardFile(resourceType string, group string, nameParts ...string) GrafanaDashboardFile {
+	return GrafanaDashboardFile{
+		ResourceType: resourceType,
+		Group:        group,
+		Name:         getGrafanaDashboardFileName(nameParts...),
+	}
+}
+
+// Write the dashboard file.  The version is bumped from the version of the previously generated file.
+func (writer *FileGrafanaDashboardWriter) write(dashboard *GrafanaDashboard, file GrafanaDashboardFile) {
+	outputFile := file.Name + ".json"
+	if len(writer.outputDir) > 0 {
+		dashboardDir := writer.getDashboardDir(file)
+		err := os.MkdirAll(dashboardDir, 0755)
+		if err != nil {
+			log.Fatalf("Error creating dashboard directory: %v", err)
+		}
+
+		outputFile = filepath.Join(dashboardDir, outputFile)
+	}
+
+	dashboard.ParsedJson["version"] = 1
+	if previousContents, err := ioutil.ReadFile(outputFile); err == nil {
+		var previousDashboard map[string]interface{}
+		if json.Unmarshal(previousContents, &previousDashboard) == nil {
+			if version, ok := previousDashboard["version"].(float64); ok {
+				dashboard.ParsedJson["version"] = int(version) + 1
+			}
+		}
+	}
+
+	generatedDashboard, err := json.MarshalIndent(dashboard.ParsedJson, "", " ")
+	if err != nil {
+		log.Fatalf("Error generating dashboard: %v", err)
+	}
+
+	err = ioutil.WriteFile(outputFile, generatedDashboard, 0644)
+	if err != nil {
+		log.Fatalf("Error writing dashboard file: %v", err)
+	}
+
+	fmt.Printf("Created %s\n", outputFile)
+}
+
+// Get the directory of the dashboard and remember its Grafana folder for the provisioning file
+func (writer *FileGrafanaDashboardWriter) getDashboardDir(file GrafanaDashboardFile) string {
+	dashboardsDir := writer.outputDir
+	if writer.isProvisioningEnabled {
+		dashboardsDir = filepath.Join(dashboardsDir, "dashboards")
+	}
+
+	// Grafana provisions the dashboards of subdirectories too, so every dashboard directory is a leaf.  Dashboards that
+	// are not generated per group go to the default directory of the resource type.
+	dashboardDir := filepath.Join(dashboardsDir, getGrafanaFileName(file.ResourceType), "default")
+	folder := file.ResourceType
+	if len(file.Group) > 0 {
+		dashboardDir = filepath.Join(dashboardsDir, getGrafanaFileName(file.ResourceType), getGrafanaFileName(file.Group))
+		folder += " - " + file.Group
+	}
+
+	writer.folders[dashboardDir] = folder
+	return dashboardDir
+}
+
+// Write the provisioning files, referencing the dashboard directories by their path on the Grafana server
+func (writer *FileGrafanaDashboardWriter) close() {
+	if writer.isProvisioningEnabled {
+		dashboardDirs := make([]string, 0)
+		for dashboardDir := range writer.folders {
+			dashboardDirs = append(dashboardDirs, dashboardDir)
+		}
+
+		sort.Strings(dashboardDirs)
+
+		providerFile := GrafanaDashboardProviderFile{ApiVersion: 1}
+		for _, dashboardDir := range dashboardDirs {
+			relativeDashboardDir, err := filepath.Rel(writer.outputDir, dashboardDir)
+			if err != nil {
+				log.Fatalf("Error getting dashboard directory path: %v", err)
+			}
+
+			// Grafana runs on Linux more often than not, e.g. in a container, so the path uses forward slashes
+			dashboardPath := path.Join(filepath.ToSlash(writer.provisioningPath), filepath.ToSlash(relativeDashboardDir))
+			providerFile.Providers = append(providerFile.Providers, newGrafanaDashboardProvider(writer.folders[dashboardDir], dashboardPath))
+		}
+
+		writeGrafanaProvisioningFile(filepath.Join(writer.outputDir, "provisioning", "dashboards", "armclient.yaml"), providerFile)
+	}
+
+	if writer.isDataSourceProvisioningEnabled {
+		dataSourceFile := GrafanaDataSourceFile{
+			ApiVersion:  1,
+			DataSources: []GrafanaDataSource{newAzureMonitorGrafanaDataSource(writer.dataSourceName, writer.credentials)},
+		}
+
+		writeGrafanaProvisioningFile(filepath.Join(writer.outputDir, "provisioning", "datasources", "armclient.yaml"), dataSourceFile)
+	}
+}
+
+// Upload the dashboard, replacing the dashboard with the same uid.  The version is that of the existing dashboard, which
+// Grafana bumps on save.
+func (writer *ApiGrafanaDashboardWriter) write(dashboard *GrafanaDashboard, file GrafanaDashboardFile) {
+	title, _ := dashboard.ParsedJson["title"].(string)
+	uid, _ := dashboard.ParsedJson["uid"].(string)
+
+	version, exists := 0, false
+	if len(uid) > 0 {
+		var err error
+		version, exists, err = writer.grafanaClient.getDashboardVersion(uid)
+		if err != nil {
+			log.Errorf("Error getting dashboard %s: %v", title, err)
+			writer.failedCount++
+			return
+		}
+	}
+
+	dashboard.ParsedJson["version"] = version
+
+	response, err := writer.grafanaClient.saveDashboard(dashboard, writer.folder, writer.message)
+	if err != nil {
+		log.Errorf("Error uploading dashboard %s: %v", title, err)
+		writer.failedCount++
+		return
+	}
+
+	if !exists {
+		writer.createdCount++
+		fmt.Printf("Created dashboard %s: %s\n", title, response.Url)
+	} else {
+		writer.updatedCount++
+		fmt.Printf("Updated dashboard %s: %s\n", title, response.Url)
+	}
+}
+
+// Print the upload summary and exit with non-zero code if any upload failed
+func (writer *ApiGrafanaDashboardWriter) close() {
+	fmt.Printf("\n%d dashboards created, %d updated, %d failed\n", writer.createdCount, writer.updatedCount, writer.failedCount)
+	if writer.failedCount > 0 {
+		os.Exit(1)
+	}
+}
+
+func getGrafanaDashboardFileName(nameParts ...string) string {
+	return getGrafanaFileName("dashboard_" + strings.Join(nameParts, "_"))
+}
+
+func getGrafanaFileName(name string) string {
+	fileName := strings.ToLower(name)
+	fileName = strings.Replace(fileName, " ", "_", -1)
+	fileName = strings.Replace(fileName, "/", "_", -1)
+	fileName = strings.Replace(fileName, ".", "_", -1)
+	return fileName
+}
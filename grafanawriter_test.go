@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestFileGrafanaDashboardWriterBumpsVersion(t *testing.T) {
+	outputDir := t.TempDir()
+	writer := NewGrafanaDashboardWriter(GrafanaGenerateOptions{OutputDir: outputDir}, AzureCredentials{})
+	file := newGrafanaDashboardFile("microsoft.storage-storageaccounts", "westus", "transactions")
+	outputFile := filepath.Join(outputDir, "microsoft_storage-storageaccounts", "westus", "dashboard_transactions.json")
+
+	for expectedVersion := 1; expectedVersion <= 3; expectedVersion++ {
+		dashboard := NewGrafanaDashboard(`{"schemaVersion":36,"panels":[],"version":7}`)
+		dashboard.setUid("transactions", "westus")
+		writer.write(dashboard, file)
+
+		contents, err := ioutil.ReadFile(outputFile)
+		if err != nil {
+			t.Fatalf("dashboard file not written: %v", err)
+		}
+
+		var writtenDashboard map[string]interface{}
+		json.Unmarshal(contents, &writtenDashboard)
+		if writtenDashboard["version"] != float64(expectedVersion) || writtenDashboard["uid"] != getGrafanaDashboardUid("transactions", "westus") {
+			t.Errorf("expected version %d, got %v with uid %v", expectedVersion, writtenDashboard["version"], writtenDashboard["uid"])
+		}
+	}
+}
+
+func TestFileGrafanaDashboardWriterProvisioningPath(t *testing.T) {
+	tests := []struct {
+		provisioningPath string
+		expectedPaths    []string
+	}{
+		{"", []string{"OUTPUT/dashboards/microsoft_storage-storageaccounts/default", "OUTPUT/dashboards/microsoft_storage-storageaccounts/westus"}},
+		{"/var/lib/grafana/armclient", []string{"/var/lib/grafana/armclient/dashboards/microsoft_storage-storageaccounts/default", "/var/lib/grafana/armclient/dashboards/microsoft_storage-storageaccounts/westus"}},
+		{"/var/lib/grafana/armclient/", []string{"/var/lib/grafana/armclient/dashboards/microsoft_storage-storageaccounts/default", "/var/lib/grafana/armclient/dashboards/microsoft_storage-storageaccounts/westus"}},
+	}
+
+	workingDir, _ := os.Getwd()
+	defer os.Chdir(workingDir)
+
+	// The output directory is relative to the working directory, as usual
+	for _, test := range tests {
+		os.Chdir(t.TempDir())
+		outputDir, _ := filepath.Abs("output")
+		options := GrafanaGenerateOptions{OutputDir: "output", IsProvisioningEnabled: true, ProvisioningPath: test.provisioningPath}
+		writer := NewGrafanaDashboardWriter(options, AzureCredentials{})
+		for _, group := range []string{"westus", ""} {
+			writer.write(NewGrafanaDashboard(`{"schemaVersion":36,"panels":[]}`), newGrafanaDashboardFile("microsoft.storage-storageaccounts", group, "transactions"))
+		}
+
+		writer.close()
+
+		contents, err := ioutil.ReadFile(filepath.Join(outputDir, "provisioning", "dashboards", "armclient.yaml"))
+		if err != nil {
+			t.Fatalf("provisioning file not written: %v", err)
+		}
+
+		var providerFile GrafanaDashboardProviderFile
+		if err := yaml.Unmarshal(contents, &providerFile); err != nil {
+			t.Fatalf("provisioning file does not parse: %v", err)
+		}
+
+		paths := make([]string, 0)
+		for _, provider := range providerFile.Providers {
+			paths = append(paths, strings.Replace(provider.Options.Path, filepath.ToSlash(outputDir), "OUTPUT", 1))
+		}
+
+		if !reflect.DeepEqual(paths, test.expectedPaths) {
+			t.Errorf("%q: expected paths %v, got %v", test.provisioningPath, test.expectedPaths, paths)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+func (processor *CommandProcessor) processGraphCommand(query string, subscriptionIds []string, maxContinuation int, outputFormat string) {
+	if len(subscriptionIds) == 0 {
+		subscriptionIds = []string{processor.azureClient.config.Credentials.SubscriptionID}
+	}
+
+	tableData := processor.azureClient.queryResourceGraph(query, subscriptionIds, maxContinuation)
+	printOutput(outputFormat, tableData.getOutputTable(), tableData.getRowObjects())
+}
+
+// Find the Azure resources on the subscription through the given backend.  The graph backend filters by resource type
+// and kind on the server side; the arm backend returns every resource and leaves filtering to the caller.
+func (processor *CommandProcessor) getAzureResources(maxContinuation int, resourceBackend string, resourceType string, resourceKind string) []ArmResource {
+	if resourceBackend == GraphResourceBackend {
+		return processor.azureClient.getAzureResourcesFromResourceGraph(maxContinuation, resourceType, resourceKind)
+	}
+
+	return processor.azureClient.getAzureResources(maxContinuation)
+}
+
+// Find the Azure resources on the subscription that match the filter
+func (processor *CommandProcessor) getSelectedAzureResources(maxContinuation int, filter ArmResourceFilter) []ArmResource {
+	return filter.filter(processor.azureClient.getAzureResources(maxContinuation))
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ResourceGraphApiVersion = "2021-03-01"
+	ResourceGraphPageSize   = 1000
+
+	ArmResourceBackend   = "arm"
+	GraphResourceBackend = "graph"
+)
+
+type ResourceGraphQueryRequest struct {
+	Subscriptions []string                  `json:"subscriptions"`
+	Query         string                    `json:"query"`
+	Options       ResourceGraphQueryOptions `json:"options"`
+}
+
+type ResourceGraphQueryOptions struct {
+	SkipToken    string `json:"$skipToken,omitempty"`
+	Top          int    `json:"$top,omitempty"`
+	ResultFormat string `json:"resultFormat"`
+}
+
+type ResourceGraphQueryResponse struct {
+	TotalRecords int64                  `json:"totalRecords"`
+	Count        int64                  `json:"count"`
+	Data         ResourceGraphTableData `json:"data"`
+	SkipToken    string                 `json:"$skipToken"`
+}
+
+type ResourceGraphTableData struct {
+	Columns []ResourceGraphColumn `json:"columns"`
+	Rows    [][]interface{}       `json:"rows"`
+}
+
+type ResourceGraphColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Run the KQL query against Azure Resource Graph across the given subscriptions, following $skipToken continuation tokens.
+// The pages are merged into a single table.
+func (azureClient *AzureClient) queryResourceGraph(query string, subscriptionIds []string, maxContinuation int) ResourceGraphTableData {
+	targetUrl := fmt.Sprintf("/providers/Microsoft.ResourceGraph/resources?api-version=%s", ResourceGraphApiVersion)
+
+	request := ResourceGraphQueryRequest{
+		Subscriptions: subscriptionIds,
+		Query:         query,
+		Options: ResourceGraphQueryOptions{
+			Top:          ResourceGraphPageSize,
+			ResultFormat: "table",
+		},
+	}
+
+	var tableData ResourceGraphTableData
+	for i := 0; i <= maxContinuation; i++ {
+		graphResponse := convertToResourceGraphQueryResponse(azureClient.getJsonResponseBody("POST", targetUrl, request))
+
+		tableData.Columns = graphResponse.Data.Columns
+		tableData.Rows = append(tableData.Rows, graphResponse.Data.Rows...)
+
+		if len(graphResponse.SkipToken) == 0 {
+			break
+		}
+
+		request.Options.SkipToken = graphResponse.SkipToken
+	}
+
+	return tableData
+}
+
+// Find the Azure resources through Azure Resource Graph.  This is much faster than the ARM resources API on large
+// subscriptions and filters by resource type and kind on the server side.
+func (azureClient *AzureClient) getAzureResourcesFromResourceGraph(maxContinuation int, resourceType string, resourceKind string) []ArmResource {
+	query := "Resources"
+	if len(resourceType) > 0 {
+		query += fmt.Sprintf(" | where type =~ '%s'", escapeKqlString(resourceType))
+	}
+
+	if len(resourceKind) > 0 {
+		query += fmt.Sprintf(" | where kind =~ '%s'", escapeKqlString(resourceKind))
+	}
+
+	query += " | project id, name, type, kind, location, sku, tags"
+
+	tableData := azureClient.queryResourceGraph(query, []string{azureClient.config.Credentials.SubscriptionID}, maxContinuation)
+
+	armResources := make([]ArmResource, 0)
+	for _, row := range tableData.getRowObjects() {
+		// Round trip through JSON so the row maps onto the same contract as the ARM resources API
+		rowJson, err := json.Marshal(row)
+		if err != nil {
+			log.Fatalf("Error serializing Resource Graph row: %v", err)
+		}
+
+		var armResource ArmResource
+		err = json.Unmarshal(rowJson, &armResource)
+		if err != nil {
+			log.Fatalf("Error unmarshalling Resource Graph row: %v", err)
+		}
+
+		armResources = append(armResources, armResource)
+	}
+
+	return armResources
+}
+
+// Convert the rows into objects keyed by column name
+func (tableData *ResourceGraphTableData) getRowObjects() []map[string]interface{} {
+	rowObjects := make([]map[string]interface{}, 0)
+	for _, row := range tableData.Rows {
+		rowObject := make(map[string]interface{})
+		for index, column := range tableData.Columns {
+			if index < len(row) {
+				rowObject[column.Name] = row[index]
+			}
+		}
+
+		rowObjects = append(rowObjects, rowObject)
+	}
+
+	return rowObjects
+}
+
+func (tableData *ResourceGraphTableData) getOutputTable() OutputTable {
+	table := OutputTable{}
+	for _, column := range tableData.Columns {
+		table.Headers = append(table.Headers, column.Name)
+	}
+
+	for _, row := range tableData.Rows {
+		columns := make([]string, 0)
+		for _, cell := range row {
+			columns = append(columns, formatResourceGraphCell(cell))
+		}
+
+		table.addRow(columns...)
+	}
+
+	return table
+}
+
+// Format the cell for text and csv output.  Nested objects such as tags and properties are printed as compact JSON.
+func formatResourceGraphCell(cell interface{}) string {
+	switch value := cell.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case map[string]interface{}, []interface{}:
+		cellJson, err := json.Marshal(value)
+		if err != nil {
+			log.Fatalf("Error serializing Resource Graph cell: %v", err)
+		}
+
+		return string(cellJson)
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
+
+// Escape a value for a single-quoted KQL string literal.  The backslashes are escaped first, so a trailing backslash
+// cannot escape the closing quote.
+func escapeKqlString(value string) string {
+	value = strings.Replace(value, "\\", "\\\\", -1)
+	return strings.Replace(value, "'", "\\'", -1)
+}
+
+func convertToResourceGraphQueryResponse(body []byte) ResourceGraphQueryResponse {
+	var graphResponse ResourceGraphQueryResponse
+	err := json.Unmarshal(body, &graphResponse)
+	if err != nil {
+		log.Fatalf("Error unmarshalling Resource Graph response body: %v", err)
+	}
+
+	return graphResponse
+}
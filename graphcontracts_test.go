@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestEscapeKqlString(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"Microsoft.Storage/storageAccounts", "Microsoft.Storage/storageAccounts"},
+		{"it's", `it\'s`},
+		{`trailing\`, `trailing\\`},
+		{`\' or 1==1 //`, `\\\' or 1==1 //`},
+	}
+
+	for _, test := range tests {
+		if escaped := escapeKqlString(test.value); escaped != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.value, test.expected, escaped)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func (processor *CommandProcessor) processGroupsListCommand(maxContinuation int, outputFormat string) {
+	resourceGroups := processor.azureClient.getResourceGroups(maxContinuation)
+	printOutput(outputFormat, getResourceGroupsOutputTable(resourceGroups), resourceGroups)
+}
+
+func (processor *CommandProcessor) processGroupsShowCommand(resourceGroupName string, outputFormat string) {
+	resourceGroup := processor.azureClient.getResourceGroup(resourceGroupName)
+	printOutput(outputFormat, getResourceGroupsOutputTable([]ResourceGroup{resourceGroup}), resourceGroup)
+}
+
+func (processor *CommandProcessor) processGroupsCreateCommand(resourceGroupName string, location string, tags map[string]string) {
+	resourceGroup := processor.azureClient.createResourceGroup(resourceGroupName, location, tags)
+	fmt.Printf("Resource group %s in %s: %s\n", resourceGroup.Name, resourceGroup.Location, resourceGroup.Properties.ProvisioningState)
+}
+
+// Delete the resource group after confirmation, since this also deletes all its resources
+func (processor *CommandProcessor) processGroupsDeleteCommand(resourceGroupName string, isConfirmed bool) {
+	if !isConfirmed && !confirm(fmt.Sprintf("Do you want to delete resource group %s and all its resources?", resourceGroupName)) {
+		fmt.Println("Deletion canceled")
+		return
+	}
+
+	processor.azureClient.deleteResourceGroup(resourceGroupName)
+	fmt.Printf("Deleted resource group %s\n", resourceGroupName)
+}
+
+// Save the resource group as an ARM template file.  Resources that cannot be exported are reported as warnings.
+func (processor *CommandProcessor) processGroupsExportCommand(resourceGroupName string, templateFile string) {
+	result := processor.azureClient.exportResourceGroupTemplate(resourceGroupName)
+	if result.Error != nil {
+		log.Warnf("Resource group %s was only partially exported: %s", resourceGroupName, result.Error.String())
+	}
+
+	if len(result.Template) == 0 {
+		log.Fatalf("No template was exported for resource group %s", resourceGroupName)
+	}
+
+	var template bytes.Buffer
+	err := json.Indent(&template, result.Template, "", "  ")
+	if err != nil {
+		log.Fatalf("Error formatting exported template: %v", err)
+	}
+
+	err = ioutil.WriteFile(templateFile, template.Bytes(), 0644)
+	if err != nil {
+		log.Fatalf("Error writing template file: %v", err)
+	}
+
+	fmt.Printf("Exported resource group %s to %s\n", resourceGroupName, templateFile)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	LocksApiVersion = "2016-09-01"
+
+	CanNotDeleteLockLevel = "CanNotDelete"
+	ReadOnlyLockLevel     = "ReadOnly"
+)
+
+type ManagementLock struct {
+	Id         string                   `json:"id"`
+	Name       string                   `json:"name"`
+	Properties ManagementLockProperties `json:"properties"`
+}
+
+type ManagementLockProperties struct {
+	Level string `json:"level"`
+	Notes string `json:"notes,omitempty"`
+}
+
+type ManagementLockRequest struct {
+	Properties ManagementLockProperties `json:"properties"`
+}
+
+func getLocksUrl(scope string) string {
+	return normalizeResourceId(scope) + "/providers/Microsoft.Authorization/locks"
+}
+
+// List the locks at the scope.  At subscription and resource group scope this includes the locks on the resources below.
+func (azureClient *AzureClient) getLocks(scope string, maxContinuation int) []ManagementLock {
+	targetUrl := fmt.Sprintf("%s?api-version=%s", getLocksUrl(scope), LocksApiVersion)
+
+	locks := make([]ManagementLock, 0)
+	for _, value := range azureClient.getPagedValues(targetUrl, maxContinuation) {
+		var lock ManagementLock
+		err := json.Unmarshal(value, &lock)
+		if err != nil {
+			log.Fatalf("Error unmarshalling lock response body: %v", err)
+		}
+
+		locks = append(locks, lock)
+	}
+
+	return locks
+}
+
+func (azureClient *AzureClient) createLock(scope string, lockName string, level string, notes string) ManagementLock {
+	targetUrl := fmt.Sprintf("%s/%s?api-version=%s", getLocksUrl(scope), lockName, LocksApiVersion)
+
+	request := ManagementLockRequest{
+		Properties: ManagementLockProperties{
+			Level: level,
+			Notes: notes,
+		},
+	}
+
+	response := azureClient.sendJsonHttpMessage("PUT", targetUrl, request)
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusCreated {
+		log.Fatalf("Error creating lock - status code: %d", response.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		log.Fatalf("Error reading body of response: %v", err)
+	}
+
+	var lock ManagementLock
+	err = json.Unmarshal(body, &lock)
+	if err != nil {
+		log.Fatalf("Error unmarshalling lock response body: %v", err)
+	}
+
+	return lock
+}
+
+// Delete the lock.  Returns false if the lock does not exist.
+func (azureClient *AzureClient) deleteLock(scope string, lockName string) bool {
+	targetUrl := fmt.Sprintf("%s/%s?api-version=%s", getLocksUrl(scope), lockName, LocksApiVersion)
+
+	response := azureClient.sendHttpMessage("DELETE", targetUrl)
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusNoContent {
+		log.Fatalf("Error deleting lock - status code: %d", response.StatusCode)
+	}
+
+	return response.StatusCode == http.StatusOK
+}
+
+// The scope the lock applies to, i.e. the lock id without the lock provider suffix
+func (lock *ManagementLock) getScope() string {
+	index := strings.LastIndex(strings.ToLower(lock.Id), "/providers/microsoft.authorization/locks/")
+	if index < 0 {
+		return ""
+	}
+
+	return lock.Id[:index]
+}
+
+// Returns whether the lock applies to the resource, either directly or inherited from the subscription or resource group
+func (lock *ManagementLock) isApplicable(resourceId string) bool {
+	scope := strings.ToLower(lock.getScope())
+	resourceId = strings.ToLower(normalizeResourceId(resourceId))
+
+	return len(scope) > 0 && (resourceId == scope || strings.HasPrefix(resourceId, scope+"/"))
+}
+
+// Select the resources that no CanNotDelete lock applies to.  ReadOnly locks also prevent deletion, so they count too.
+func getUnlockedArmResources(armResources []ArmResource, locks []ManagementLock) []ArmResource {
+	unlockedResources := make([]ArmResource, 0)
+	for _, armResource := range armResources {
+		isLocked := false
+		for _, lock := range locks {
+			if lock.isApplicable(armResource.Id) &&
+				(strings.EqualFold(lock.Properties.Level, CanNotDeleteLockLevel) || strings.EqualFold(lock.Properties.Level, ReadOnlyLockLevel)) {
+				isLocked = true
+				break
+			}
+		}
+
+		if !isLocked {
+			unlockedResources = append(unlockedResources, armResource)
+		}
+	}
+
+	return unlockedResources
+}
+
+func getLocksOutputTable(locks []ManagementLock) OutputTable {
+	table := OutputTable{
+		Headers: []string{"Name", "Level", "Scope", "Notes"},
+	}
+
+	for _, lock := range locks {
+		table.addRow(lock.Name, lock.Properties.Level, lock.getScope(), lock.Properties.Notes)
+	}
+
+	return table
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func (processor *CommandProcessor) processLocksListCommand(scope string, maxContinuation int, outputFormat string) {
+	if len(scope) == 0 {
+		scope = processor.azureClient.getSubscriptionScope()
+	}
+
+	locks := processor.azureClient.getLocks(scope, maxContinuation)
+	printOutput(outputFormat, getLocksOutputTable(locks), locks)
+}
+
+// Report the selected resources that can be deleted because no lock applies to them and exit with non-zero code if any
+func (processor *CommandProcessor) processLocksAuditCommand(maxContinuation int, filter ArmResourceFilter, outputFormat string) {
+	armResources := processor.getSelectedAzureResources(maxContinuation, filter)
+	locks := processor.azureClient.getLocks(processor.azureClient.getSubscriptionScope(), maxContinuation)
+
+	unlockedResources := getUnlockedArmResources(armResources, locks)
+	printOutput(outputFormat, getArmResourcesOutputTable(unlockedResources), unlockedResources)
+
+	if outputFormat == TextOutputFormat {
+		fmt.Printf("\n%d of %d resources lack a %s lock\n", len(unlockedResources), len(armResources), CanNotDeleteLockLevel)
+	}
+
+	if len(unlockedResources) > 0 {
+		os.Exit(1)
+	}
+}
+
+func (processor *CommandProcessor) processLocksCreateCommand(scope string, lockName string, level string, notes string) {
+	if len(scope) == 0 {
+		scope = processor.azureClient.getSubscriptionScope()
+	}
+
+	lock := processor.azureClient.createLock(scope, lockName, level, notes)
+	fmt.Printf("Created %s lock %s on %s\n", lock.Properties.Level, lock.Name, lock.getScope())
+}
+
+func (processor *CommandProcessor) processLocksDeleteCommand(scope string, lockName string) {
+	if len(scope) == 0 {
+		scope = processor.azureClient.getSubscriptionScope()
+	}
+
+	if processor.azureClient.deleteLock(scope, lockName) {
+		fmt.Printf("Deleted lock %s on %s\n", lockName, scope)
+	} else {
+		fmt.Printf("Lock %s does not exist on %s\n", lockName, scope)
+	}
+}
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 )
 
+// Logs go to stderr, so stdout only carries the command output, e.g. --output json
 func initLogging(isDebugEnabled bool) {
-	log.SetOutput(os.Stdout)
+	log.SetOutput(os.Stderr)
 
 	if isDebugEnabled {
 		log.SetLevel(log.DebugLevel)
@@ -17,10 +21,80 @@ func initLogging(isDebugEnabled bool) {
 	}
 }
 
+// Add the flags that select Azure resources to the command
+func addArmResourceFilterFlags(command *kingpin.CmdClause) *ArmResourceFilter {
+	filter := &ArmResourceFilter{}
+	command.Flag("resourcetype", "Only select resources of this Azure Resource Manager (ARM) resource type").Default("").StringVar(&filter.ResourceType)
+	command.Flag("kind", "Only select resources of this kind").Default("").StringVar(&filter.Kind)
+	command.Flag("resourcegroup", "Only select resources in this resource group").Default("").StringVar(&filter.ResourceGroup)
+	command.Flag("location", "Only select resources in this location").Default("").StringVar(&filter.Location)
+	command.Flag("tag", "Only select resources with this tag {key}={value}.  Use {key}=* to match any value.  Repeat for multiple tags.").StringMapVar(&filter.Tags)
+	return filter
+}
+
+// Flag action recording that the flag was given on the command line.  Kingpin only runs the actions of the flags that
+// were parsed, not of the flags set to their default.
+func setByUser(isSet *bool) kingpin.Action {
+	return func(context *kingpin.ParseContext) error {
+		*isSet = true
+		return nil
+	}
+}
+
+// Returns an error if any of the flags was set along with the flag they do not apply to
+func checkFlagsNotSet(flag string, otherFlags []string, isOtherFlagSet []bool) error {
+	setFlags := make([]string, 0)
+	for index, otherFlag := range otherFlags {
+		if isOtherFlagSet[index] {
+			setFlags = append(setFlags, otherFlag)
+		}
+	}
+
+	if len(setFlags) > 0 {
+		return fmt.Errorf("%s cannot be combined with %s", flag, strings.Join(setFlags, ", "))
+	}
+
+	return nil
+}
+
+// Whether the command lists the resources on the subscription.  grafana generate --variables does not.
+func isListingAzureResources(command string, isGrafanaVariablesEnabled bool) bool {
+	switch command {
+	case "resources list", "resources snapshot", "resources export", "grafana validate":
+		return true
+	case "grafana generate":
+		return !isGrafanaVariablesEnabled
+	}
+
+	return false
+}
+
+// Create the Grafana template source of the --templates flag.  Sources other than local directories are read through
+// the template cache.
+func mustCreateGrafanaTemplateSource(specification string, cacheDir string, isOffline bool) GrafanaTemplateSource {
+	templateSource, err := NewGrafanaTemplateSource(specification)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cacheableSource, ok := templateSource.(CacheableGrafanaTemplateSource)
+	if !ok {
+		return templateSource
+	}
+
+	cache, err := NewGrafanaTemplateCache(cacheDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return NewCachedGrafanaTemplateSource(cacheableSource, cache, isOffline)
+}
+
 func main() {
 	// flags
 	configFile := kingpin.Flag("config.file", "Azure configuration file").Default("sample-azure.yml").String()
 	isDebugEnabled := kingpin.Flag("debug", "Debug flag").Default("false").Bool()
+	isPreflightEnabled := kingpin.Flag("preflight", "Warn when the service principal lacks read access to the subscription before listing resources.  Disable with --no-preflight.").Default("true").Bool()
 
 	// get command
 	getCommand := kingpin.Command("get", "Perform GET <url> against Azure Resource Manager API")
@@ -29,23 +103,220 @@ func main() {
 	// summary command
 	summaryCommand := kingpin.Command("resources", "Print out the Azure resources that exist on this subscription")
 	summaryCommandMaxContinuation := summaryCommand.Flag("maxcontinuation", "The max number of continuations to follow when calling ARM API.  Default to 10.").Default("10").Int()
+	summaryCommandFilter := addArmResourceFilterFlags(summaryCommand)
+	summaryCommandOutputFormat := summaryCommand.Flag("output", "The output format: text, json or csv.  Default to text.").Default(TextOutputFormat).Enum(OutputFormats...)
+	listCommand := summaryCommand.Command("list", "Print out the Azure resources that exist on this subscription.  This is the default.").Default()
+	listCommandSummary := listCommand.Flag("summary", "Print out the resource counts by location, type, kind, SKU tier and resource group instead of each resource").Default("false").Bool()
+	snapshotCommand := summaryCommand.Command("snapshot", "Save the Azure resources that exist on this subscription to a snapshot file")
+	snapshotCommandFile := snapshotCommand.Arg("file", "The snapshot file").Required().String()
+	exportCommand := summaryCommand.Command("export", "Append the Azure resources that exist on this subscription to a database as a new snapshot")
+	exportCommandSqliteFile := exportCommand.Flag("sqlite", "The SQLite database file").Required().String()
+	diffCommand := summaryCommand.Command("diff", "Show the added, removed and changed Azure resources between two snapshot files")
+	diffCommandBeforeFile := diffCommand.Arg("before", "The older snapshot file").Required().String()
+	diffCommandAfterFile := diffCommand.Arg("after", "The newer snapshot file").Required().String()
 
 	// grafana command
-	grafanaCommand := kingpin.Command("grafana", "Generate Grafana dashboard JSON files for given Azure resource type.")
-	grafanaCommandTitle := grafanaCommand.Flag("title", "This will be used as prefix in the dashboard title").Required().String()
-	grafanaCommandDataSourceName := grafanaCommand.Flag("datasource", "The Azure Monitor data source name on Grafana").Required().String()
+	grafanaCommand := kingpin.Command("grafana", "Generate or validate Grafana dashboards for given Azure resource type.")
 	grafanaCommandResourceType := grafanaCommand.Flag("resourcetype", "The Azure Resource Manager (ARM) resource type").Required().String()
 	grafanaCommandSubResourceType := grafanaCommand.Flag("subresourcetype", "The sub-resource type underneath the ARM resource type.").Default("").String()
 	grafanaCommandSubResourceName := grafanaCommand.Flag("subresourcename", "The resource name of the sub-resource type underneath the ARM resource type.").Default("").String()
 	grafanaCommandKind := grafanaCommand.Flag("kind", "The kind property on the Azure Resource Manager (ARM) resource type.  This is optional.").Default("").String()
-	grafanaCommandMaxDashboardResources := grafanaCommand.Flag("maxdashboardresource", "The max number of Azure resources to include in each dashboard.  Default to 10.").Default("10").Int()
 	grafanaCommandMaxContinuation := grafanaCommand.Flag("maxcontinuation", "The max number of continuations to follow when calling ARM API.  Default to 10.").Default("10").Int()
+	grafanaCommandResourceBackend := grafanaCommand.Flag("backend", "The API used to find the Azure resources: arm or graph (Azure Resource Graph).  Default to arm.").Default(ArmResourceBackend).Enum(ArmResourceBackend, GraphResourceBackend)
+	grafanaCommandTemplates := grafanaCommand.Flag("templates", "The dashboard templates: a local directory, github:{owner}/{repo}[@{ref}], an HTTPS base URL or a .zip or .tar.gz file.  Default to github:asheniam/azure-grafana-dashboard-templates@master.").Default("").String()
+	grafanaCommandTemplateCacheDir := grafanaCommand.Flag("cache-dir", "The template cache directory.  Default to armclient/templates in the user cache directory.").Default("").String()
+	grafanaCommandOffline := grafanaCommand.Flag("offline", "Only use the cached templates.  Run templates sync first.").Default("false").Bool()
+	var isGrafanaMaxDashboardResourcesSet, isGrafanaLayoutSet, isGrafanaGroupBySet bool
+	grafanaGenerateCommand := grafanaCommand.Command("generate", "Generate Grafana dashboard JSON files for given Azure resource type.  This is the default.").Default()
+	grafanaGenerateCommandTitle := grafanaGenerateCommand.Flag("title", "This will be used as prefix in the dashboard title").Required().String()
+	grafanaGenerateCommandDataSourceName := grafanaGenerateCommand.Flag("datasource", "The Azure Monitor data source name on Grafana").Required().String()
+	grafanaGenerateCommandMaxDashboardResources := grafanaGenerateCommand.Flag("maxdashboardresource", "The max number of Azure resources to include in each dashboard.  Dashboards with more resources are split into pages.  Default to 10.").Default("10").Action(setByUser(&isGrafanaMaxDashboardResourcesSet)).Int()
+	grafanaGenerateCommandLayout := grafanaGenerateCommand.Flag("layout", "targets adds each resource as a target of the template panels, panels clones the template panels for each resource and repeat lets Grafana repeat the template panels for each selected resource.  Default to targets.").Default(TargetsGrafanaLayout).Action(setByUser(&isGrafanaLayoutSet)).Enum(GrafanaLayouts...)
+	grafanaGenerateCommandGroupBy := grafanaGenerateCommand.Flag("group-by", "Generate a dashboard for each region, resourcegroup, subscription or value of tag:{key}, or none for a single dashboard.  Default to region, which adds a dashboard for all regions.").Default(RegionGrafanaGroupBy).Action(setByUser(&isGrafanaGroupBySet)).String()
+	grafanaGenerateCommandVariables := grafanaGenerateCommand.Flag("variables", "Generate one dashboard for each template that selects the resource with subscription, resource group and resource template variables").Default("false").Bool()
+	grafanaGenerateCommandGrafanaUrl := grafanaGenerateCommand.Flag("grafana-url", "Upload the dashboards to the Grafana at this URL instead of writing files").Default("").String()
+	grafanaGenerateCommandGrafanaToken := grafanaGenerateCommand.Flag("grafana-token", "The Grafana API key or service account token").Envar("GRAFANA_TOKEN").Default("").String()
+	grafanaGenerateCommandGrafanaFolder := grafanaGenerateCommand.Flag("folder", "The Grafana folder of the uploaded dashboards, created if it does not exist.  Default to the General folder.").Default("").String()
+	grafanaGenerateCommandGrafanaMessage := grafanaGenerateCommand.Flag("message", "The version history message of the uploaded dashboards").Default("Generated by armclient").String()
+	grafanaGenerateCommandOutputDir := grafanaGenerateCommand.Flag("output-dir", "Write the dashboards to a {resource type}/{group} folder hierarchy underneath this directory instead of the current directory").Default("").String()
+	grafanaGenerateCommandProvisioning := grafanaGenerateCommand.Flag("provisioning", "Also write a Grafana dashboard provisioning file for the folder hierarchy to the output directory").Default("false").Bool()
+	grafanaGenerateCommandProvisioningPath := grafanaGenerateCommand.Flag("provisioning-path", "The path of the output directory on the Grafana server, which prefixes the dashboard directories in the provisioning file.  Default to the absolute path of the output directory.").Default("").String()
+	grafanaGenerateCommandDataSourceProvisioning := grafanaGenerateCommand.Flag("provision-datasource", "Also write a Grafana data source provisioning file for the Azure Monitor data source to the output directory").Default("false").Bool()
+	grafanaValidateCommand := grafanaCommand.Command("validate", "Validate the Grafana dashboard templates for given Azure resource type against the metric definitions of the Azure resources.")
+	grafanaValidateCommandMaxResources := grafanaValidateCommand.Flag("maxresource", "The max number of Azure resources to validate the templates against.  Default to 10.").Default("10").Int()
+	grafanaValidateCommandOutputFormat := grafanaValidateCommand.Flag("output", "The output format: text, json or csv.  Default to text.").Default(TextOutputFormat).Enum(OutputFormats...)
+
+	// providers command
+	providersCommand := kingpin.Command("providers", "Explore the Azure resource providers, resource types and API versions")
+	providersCommandOutputFormat := providersCommand.Flag("output", "The output format: text, json or csv.  Default to text.").Default(TextOutputFormat).Enum(OutputFormats...)
+	providersCommandMaxContinuation := providersCommand.Flag("maxcontinuation", "The max number of continuations to follow when calling ARM API.  Default to 10.").Default("10").Int()
+	providersCommand.Command("list", "List the resource providers and their registration state on this subscription")
+	providersShowCommand := providersCommand.Command("show", "Show the resource types, locations and API versions of a resource provider")
+	providersShowCommandNamespace := providersShowCommand.Arg("namespace", "The resource provider namespace, e.g. Microsoft.Storage").Required().String()
+	providersTypesCommand := providersCommand.Command("types", "List the resource types exposed by the resource providers")
+	providersTypesCommandNamespace := providersTypesCommand.Flag("namespace", "Only list resource types of this resource provider namespace").Default("").String()
+	providersTypesCommandLocation := providersTypesCommand.Flag("location", "Only list resource types available in this location").Default("").String()
+	providersRegisterCommand := providersCommand.Command("register", "Register this subscription with a resource provider")
+	providersRegisterCommandNamespace := providersRegisterCommand.Arg("namespace", "The resource provider namespace").Required().String()
+	providersUnregisterCommand := providersCommand.Command("unregister", "Unregister this subscription from a resource provider")
+	providersUnregisterCommandNamespace := providersUnregisterCommand.Arg("namespace", "The resource provider namespace").Required().String()
+
+	// graph command
+	graphCommand := kingpin.Command("graph", "Run a KQL query against Azure Resource Graph")
+	graphCommandQuery := graphCommand.Arg("query", "The KQL query, e.g. \"Resources | summarize count() by type\"").Required().String()
+	graphCommandSubscriptions := graphCommand.Flag("subscription", "The subscription ID to query.  Repeat to query multiple subscriptions.  Default to the configured subscription.").Strings()
+	graphCommandMaxContinuation := graphCommand.Flag("maxcontinuation", "The max number of continuations to follow when calling Resource Graph API.  Default to 10.").Default("10").Int()
+	graphCommandOutputFormat := graphCommand.Flag("output", "The output format: text, json or csv.  Default to text.").Default(TextOutputFormat).Enum(OutputFormats...)
+
+	// metrics command
+	metricsCommand := kingpin.Command("metrics", "Query Azure Monitor metric definitions and metric values of an Azure resource")
+	metricsCommandNamespace := metricsCommand.Flag("namespace", "The metric namespace.  Default to the resource type.").Default("").String()
+	metricsCommandOutputFormat := metricsCommand.Flag("output", "The output format: text, json or csv.  Default to text.").Default(TextOutputFormat).Enum(OutputFormats...)
+	metricsDefinitionsCommand := metricsCommand.Command("definitions", "List the metric definitions of an Azure resource")
+	metricsDefinitionsCommandResourceId := metricsDefinitionsCommand.Arg("resourceid", "The Azure Resource Manager (ARM) resource ID").Required().String()
+	metricsQueryCommand := metricsCommand.Command("query", "Query the metric values of an Azure resource")
+	metricsQueryCommandResourceId := metricsQueryCommand.Arg("resourceid", "The Azure Resource Manager (ARM) resource ID").Required().String()
+	metricsQueryCommandMetricNames := metricsQueryCommand.Flag("metric", "The metric name.  Repeat to query multiple metrics.").Required().Strings()
+	metricsQueryCommandTimespan := metricsQueryCommand.Flag("timespan", "The ISO 8601 timespan {start}/{end}.  Overrides --lookback.").Default("").String()
+	metricsQueryCommandLookback := metricsQueryCommand.Flag("lookback", "The timespan ending now.  Default to 1h.").Default("1h").Duration()
+	metricsQueryCommandInterval := metricsQueryCommand.Flag("interval", "The ISO 8601 time grain, e.g. PT1M.  Default to PT5M.").Default("PT5M").String()
+	metricsQueryCommandAggregations := metricsQueryCommand.Flag("aggregation", "The aggregation type: Average, Minimum, Maximum, Total or Count.  Repeat for multiple aggregations.").Strings()
+	metricsQueryCommandDimensions := metricsQueryCommand.Flag("dimension", "The dimension filter {name}={value}.  Omit the value to split by the dimension.  Repeat for multiple dimensions.").Strings()
+
+	// activity command
+	activityCommand := kingpin.Command("activity", "Query the Azure activity log of this subscription")
+	activityCommandStart := activityCommand.Flag("start", "The RFC 3339 start time.  Overrides --lookback.").Default("").String()
+	activityCommandEnd := activityCommand.Flag("end", "The RFC 3339 end time.  Default to now.").Default("").String()
+	activityCommandLookback := activityCommand.Flag("lookback", "The time range before the end time.  Default to 1h.").Default("1h").Duration()
+	activityCommandResourceGroup := activityCommand.Flag("resourcegroup", "Only query the events of this resource group").Default("").String()
+	activityCommandResourceId := activityCommand.Flag("resourceid", "Only query the events of this resource ID").Default("").String()
+	activityCommandCaller := activityCommand.Flag("caller", "Only query the events of this caller").Default("").String()
+	activityCommandStatus := activityCommand.Flag("status", "Only query the events with this status, e.g. Failed").Default("").String()
+	activityCommandFollow := activityCommand.Flag("follow", "Keep polling the activity log for new events").Default("false").Bool()
+	activityCommandPollInterval := activityCommand.Flag("interval", "The polling interval of --follow.  Default to 30s.").Default("30s").Duration()
+	activityCommandMaxContinuation := activityCommand.Flag("maxcontinuation", "The max number of continuations to follow when calling ARM API.  Default to 10.").Default("10").Int()
+	activityCommandOutputFormat := activityCommand.Flag("output", "The output format: text, json or csv.  Default to text.").Default(TextOutputFormat).Enum(OutputFormats...)
+
+	// deploy command
+	deployCommand := kingpin.Command("deploy", "Deploy an Azure Resource Manager (ARM) template")
+	deployCommandTemplateFile := deployCommand.Flag("template", "The ARM template file").Required().String()
+	deployCommandParametersFile := deployCommand.Flag("parameters", "The ARM template parameters file").Default("").String()
+	deployCommandResourceGroup := deployCommand.Flag("resourcegroup", "Deploy to this resource group").Default("").String()
+	deployCommandManagementGroup := deployCommand.Flag("managementgroup", "Deploy to this management group").Default("").String()
+	deployCommandLocation := deployCommand.Flag("location", "The location of the deployment.  Required for subscription and management group deployments.").Default("").String()
+	deployCommandName := deployCommand.Flag("name", "The deployment name.  Default to armclient-{timestamp}.").Default("").String()
+	deployCommandMode := deployCommand.Flag("mode", "The deployment mode of resource group deployments: Incremental or Complete.  Default to Incremental.").Default("Incremental").Enum("Incremental", "Complete")
+	deployCommandNoColor := deployCommand.Flag("nocolor", "Disable colors in the what-if output").Default("false").Bool()
+	deployCommand.Command("whatif", "Preview the changes of the deployment")
+	deployCreateCommand := deployCommand.Command("create", "Submit the deployment and wait for it to complete")
+	deployCreateCommandPreview := deployCreateCommand.Flag("whatif", "Preview the changes and ask for confirmation before deploying").Default("false").Bool()
+	deployCreateCommandYes := deployCreateCommand.Flag("yes", "Do not ask for confirmation").Default("false").Bool()
+	deployCreateCommandPollInterval := deployCreateCommand.Flag("interval", "The polling interval of the deployment status.  Default to 10s.").Default("10s").Duration()
+
+	// tags command
+	tagsCommand := kingpin.Command("tags", "Audit and edit the tags of the Azure resources on this subscription")
+	tagsCommandMaxContinuation := tagsCommand.Flag("maxcontinuation", "The max number of continuations to follow when calling ARM API.  Default to 10.").Default("10").Int()
+	tagsCommandFilter := addArmResourceFilterFlags(tagsCommand)
+	tagsAuditCommand := tagsCommand.Command("audit", "Report the Azure resources that violate the tag rules.  Exits with non-zero code on violations.")
+	tagsAuditCommandRuleFile := tagsAuditCommand.Flag("rules", "The YAML tag rule file").Required().String()
+	tagsAuditCommandOutputFormat := tagsAuditCommand.Flag("output", "The output format: text, json, csv or junit.  Default to text.").Default(TextOutputFormat).Enum(append(OutputFormats, JUnitOutputFormat)...)
+	tagsAuditCommandJUnitFile := tagsAuditCommand.Flag("junit-file", "Also write the JUnit XML report to this file").Default("").String()
+
+	tagsSetCommand := tagsCommand.Command("set", "Set tags on the selected Azure resources")
+	tagsSetCommandTags := tagsSetCommand.Arg("tags", "The tags {key}={value} to set").Required().StringMap()
+	tagsSetCommandMode := tagsSetCommand.Flag("mode", "merge keeps the other existing tags, replace removes them.  Default to merge.").Default("merge").Enum("merge", "replace")
+	tagsSetCommandDryRun := tagsSetCommand.Flag("dry-run", "Only print the tag changes").Default("false").Bool()
+	tagsSetCommandConcurrency := tagsSetCommand.Flag("concurrency", "The max number of resources updated at the same time.  Default to 4.").Default("4").Int()
+	tagsRemoveCommand := tagsCommand.Command("remove", "Remove tags from the selected Azure resources")
+	tagsRemoveCommandKeys := tagsRemoveCommand.Arg("keys", "The tag keys to remove").Required().Strings()
+	tagsRemoveCommandDryRun := tagsRemoveCommand.Flag("dry-run", "Only print the tag changes").Default("false").Bool()
+	tagsRemoveCommandConcurrency := tagsRemoveCommand.Flag("concurrency", "The max number of resources updated at the same time.  Default to 4.").Default("4").Int()
+
+	// rbac command
+	rbacCommand := kingpin.Command("rbac", "Inspect role assignments and permissions")
+	rbacCommandScope := rbacCommand.Flag("scope", "The scope, e.g. /subscriptions/{id}/resourceGroups/{name}.  Default to the subscription.").Default("").String()
+	rbacCommandOutputFormat := rbacCommand.Flag("output", "The output format: text, json or csv.  Default to text.").Default(TextOutputFormat).Enum(OutputFormats...)
+	rbacAssignmentsCommand := rbacCommand.Command("assignments", "List the role assignments at the scope with their role names")
+	rbacAssignmentsCommandMaxContinuation := rbacAssignmentsCommand.Flag("maxcontinuation", "The max number of continuations to follow when calling ARM API.  Default to 10.").Default("10").Int()
+	rbacCommand.Command("permissions", "Show the effective permissions of the configured service principal at the scope")
+
+	// policy command
+	policyCommand := kingpin.Command("policy", "Show the resources that are non-compliant with Azure Policy, grouped by policy assignment and definition")
+	policyCommandResourceGroup := policyCommand.Flag("resourcegroup", "Only show the compliance of this resource group").Default("").String()
+	policyCommandMaxContinuation := policyCommand.Flag("maxcontinuation", "The max number of continuations to follow when calling ARM API.  Default to 10.").Default("10").Int()
+	policyCommandOutputFormat := policyCommand.Flag("output", "The output format: text, json or csv.  Default to text.").Default(TextOutputFormat).Enum(OutputFormats...)
+
+	// locks command
+	locksCommand := kingpin.Command("locks", "Audit and edit the management locks")
+	locksCommandMaxContinuation := locksCommand.Flag("maxcontinuation", "The max number of continuations to follow when calling ARM API.  Default to 10.").Default("10").Int()
+	locksCommandOutputFormat := locksCommand.Flag("output", "The output format: text, json or csv.  Default to text.").Default(TextOutputFormat).Enum(OutputFormats...)
+	locksListCommand := locksCommand.Command("list", "List the locks at the scope, including the locks on the resources below it").Default()
+	locksListCommandScope := locksListCommand.Flag("scope", "The subscription, resource group or resource id.  Default to the subscription.").Default("").String()
+	locksAuditCommand := locksCommand.Command("audit", "Report the selected Azure resources that lack a CanNotDelete lock.  Exits with non-zero code if any.")
+	locksAuditCommandFilter := addArmResourceFilterFlags(locksAuditCommand)
+	locksCreateCommand := locksCommand.Command("create", "Create a lock at the scope")
+	locksCreateCommandName := locksCreateCommand.Arg("name", "The name of the lock").Required().String()
+	locksCreateCommandScope := locksCreateCommand.Flag("scope", "The subscription, resource group or resource id.  Default to the subscription.").Default("").String()
+	locksCreateCommandLevel := locksCreateCommand.Flag("level", "The lock level.  Default to CanNotDelete.").Default(CanNotDeleteLockLevel).Enum(CanNotDeleteLockLevel, ReadOnlyLockLevel)
+	locksCreateCommandNotes := locksCreateCommand.Flag("notes", "Notes about the lock").Default("").String()
+	locksDeleteCommand := locksCommand.Command("delete", "Delete a lock at the scope")
+	locksDeleteCommandName := locksDeleteCommand.Arg("name", "The name of the lock").Required().String()
+	locksDeleteCommandScope := locksDeleteCommand.Flag("scope", "The subscription, resource group or resource id.  Default to the subscription.").Default("").String()
+
+	// groups command
+	groupsCommand := kingpin.Command("groups", "Manage the resource groups on this subscription")
+	groupsCommandOutputFormat := groupsCommand.Flag("output", "The output format: text, json or csv.  Default to text.").Default(TextOutputFormat).Enum(OutputFormats...)
+	groupsListCommand := groupsCommand.Command("list", "List the resource groups with their tags and provisioning state").Default()
+	groupsListCommandMaxContinuation := groupsListCommand.Flag("maxcontinuation", "The max number of continuations to follow when calling ARM API.  Default to 10.").Default("10").Int()
+	groupsShowCommand := groupsCommand.Command("show", "Show a resource group")
+	groupsShowCommandName := groupsShowCommand.Arg("name", "The name of the resource group").Required().String()
+	groupsCreateCommand := groupsCommand.Command("create", "Create or update a resource group")
+	groupsCreateCommandName := groupsCreateCommand.Arg("name", "The name of the resource group").Required().String()
+	groupsCreateCommandLocation := groupsCreateCommand.Flag("location", "The location of the resource group").Required().String()
+	groupsCreateCommandTags := groupsCreateCommand.Flag("tag", "A tag {key}={value} of the resource group.  Repeat for multiple tags.").StringMap()
+	groupsDeleteCommand := groupsCommand.Command("delete", "Delete a resource group with all its resources and wait for the deletion to complete")
+	groupsDeleteCommandName := groupsDeleteCommand.Arg("name", "The name of the resource group").Required().String()
+	groupsDeleteCommandYes := groupsDeleteCommand.Flag("yes", "Do not ask for confirmation").Default("false").Bool()
+	groupsExportCommand := groupsCommand.Command("export", "Save a resource group as an ARM template file")
+	groupsExportCommandName := groupsExportCommand.Arg("name", "The name of the resource group").Required().String()
+	groupsExportCommandFile := groupsExportCommand.Arg("file", "The template file").Required().String()
+
+	// templates command
+	templatesCommand := kingpin.Command("templates", "Manage the Grafana dashboard template cache")
+	templatesSyncCommand := templatesCommand.Command("sync", "Fetch the templates of every resource type into the template cache")
+	templatesSyncCommandTemplates := templatesSyncCommand.Flag("templates", "The dashboard templates: github:{owner}/{repo}[@{ref}], an HTTPS base URL or a .zip or .tar.gz file.  Default to github:asheniam/azure-grafana-dashboard-templates@master.").Default("").String()
+	templatesSyncCommandCacheDir := templatesSyncCommand.Flag("cache-dir", "The template cache directory.  Default to armclient/templates in the user cache directory.").Default("").String()
 
 	command := kingpin.Parse()
 
 	// initialize logging after parsing flags
 	initLogging(*isDebugEnabled)
 
+	// Check the grafana generate flags before any resource is fetched.  --variables generates a single dashboard for each
+	// template, so the flags that lay out, group and page the resources do not apply.
+	if command == "grafana generate" {
+		var err error
+		if *grafanaGenerateCommandVariables {
+			err = checkFlagsNotSet("--variables", []string{"--maxdashboardresource", "--layout", "--group-by"}, []bool{isGrafanaMaxDashboardResourcesSet, isGrafanaLayoutSet, isGrafanaGroupBySet})
+		} else {
+			err = validateGrafanaGroupBy(*grafanaGenerateCommandGroupBy)
+		}
+
+		// Uploaded dashboards are not written to files
+		if err == nil && len(*grafanaGenerateCommandGrafanaUrl) > 0 {
+			err = checkFlagsNotSet("--grafana-url", []string{"--output-dir", "--provisioning", "--provisioning-path", "--provision-datasource"}, []bool{
+				len(*grafanaGenerateCommandOutputDir) > 0,
+				*grafanaGenerateCommandProvisioning,
+				len(*grafanaGenerateCommandProvisioningPath) > 0,
+				*grafanaGenerateCommandDataSourceProvisioning,
+			})
+		}
+
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	config := &Config{}
 	err := config.loadConfig(*configFile)
 	if err != nil {
@@ -56,16 +327,199 @@ func main() {
 	environment := getCurrentEnvironment(config.Credentials.Environment)
 	processor := NewCommandProcessor(config, environment)
 
+	// Check the permissions before the commands that list the resources on the subscription
+	if *isPreflightEnabled && isListingAzureResources(command, *grafanaGenerateCommandVariables) {
+		processor.checkReadAccess()
+	}
+
 	// process commands
 	switch command {
 	case "get":
 		processor.processGetCommand(*getCommandUrl)
 		break
-	case "resources":
-		processor.processSummarizeCommand(*summaryCommandMaxContinuation)
+	case "resources list":
+		processor.processSummarizeCommand(*summaryCommandMaxContinuation, *summaryCommandFilter, *summaryCommandOutputFormat, *listCommandSummary)
+		break
+	case "resources snapshot":
+		processor.processSnapshotCommand(*summaryCommandMaxContinuation, *summaryCommandFilter, *snapshotCommandFile)
+		break
+	case "resources export":
+		processor.processSqliteExportCommand(*summaryCommandMaxContinuation, *summaryCommandFilter, *exportCommandSqliteFile)
+		break
+	case "resources diff":
+		processDiffCommand(*diffCommandBeforeFile, *diffCommandAfterFile, *summaryCommandOutputFormat)
+		break
+	case "grafana generate":
+		options := GrafanaGenerateOptions{
+			TitlePrefix:           *grafanaGenerateCommandTitle,
+			DataSourceName:        *grafanaGenerateCommandDataSourceName,
+			MaxDashboardResources: *grafanaGenerateCommandMaxDashboardResources,
+			Layout:                *grafanaGenerateCommandLayout,
+			GroupBy:               *grafanaGenerateCommandGroupBy,
+			IsVariablesEnabled:    *grafanaGenerateCommandVariables,
+			GrafanaUrl:            *grafanaGenerateCommandGrafanaUrl,
+			GrafanaToken:          *grafanaGenerateCommandGrafanaToken,
+			GrafanaFolder:         *grafanaGenerateCommandGrafanaFolder,
+			GrafanaMessage:        *grafanaGenerateCommandGrafanaMessage,
+
+			OutputDir:                       *grafanaGenerateCommandOutputDir,
+			IsProvisioningEnabled:           *grafanaGenerateCommandProvisioning,
+			IsDataSourceProvisioningEnabled: *grafanaGenerateCommandDataSourceProvisioning,
+			ProvisioningPath:                *grafanaGenerateCommandProvisioningPath,
+		}
+
+		processor.processGrafanaCommand(mustCreateGrafanaTemplateSource(*grafanaCommandTemplates, *grafanaCommandTemplateCacheDir, *grafanaCommandOffline), options, *grafanaCommandMaxContinuation, *grafanaCommandResourceType, *grafanaCommandKind, *grafanaCommandSubResourceType, *grafanaCommandSubResourceName, *grafanaCommandResourceBackend)
+		break
+	case "grafana validate":
+		processor.processGrafanaValidateCommand(mustCreateGrafanaTemplateSource(*grafanaCommandTemplates, *grafanaCommandTemplateCacheDir, *grafanaCommandOffline), *grafanaCommandMaxContinuation, *grafanaValidateCommandMaxResources, *grafanaCommandResourceType, *grafanaCommandKind, *grafanaCommandSubResourceType, *grafanaCommandSubResourceName, *grafanaCommandResourceBackend, *grafanaValidateCommandOutputFormat)
+		break
+	case "templates sync":
+		processTemplatesSyncCommand(mustCreateGrafanaTemplateSource(*templatesSyncCommandTemplates, *templatesSyncCommandCacheDir, false))
+		break
+	case "providers list":
+		processor.processProvidersListCommand(*providersCommandMaxContinuation, *providersCommandOutputFormat)
+		break
+	case "providers show":
+		processor.processProvidersShowCommand(*providersShowCommandNamespace, *providersCommandOutputFormat)
+		break
+	case "providers types":
+		processor.processProvidersTypesCommand(*providersCommandMaxContinuation, *providersTypesCommandNamespace, *providersTypesCommandLocation, *providersCommandOutputFormat)
+		break
+	case "providers register":
+		processor.processProvidersRegistrationCommand(*providersRegisterCommandNamespace, "register")
+		break
+	case "providers unregister":
+		processor.processProvidersRegistrationCommand(*providersUnregisterCommandNamespace, "unregister")
+		break
+	case "graph":
+		processor.processGraphCommand(*graphCommandQuery, *graphCommandSubscriptions, *graphCommandMaxContinuation, *graphCommandOutputFormat)
+		break
+	case "metrics definitions":
+		processor.processMetricsDefinitionsCommand(*metricsDefinitionsCommandResourceId, *metricsCommandNamespace, *metricsCommandOutputFormat)
+		break
+	case "metrics query":
+		metricQuery := MetricQuery{
+			MetricNames:  *metricsQueryCommandMetricNames,
+			Namespace:    *metricsCommandNamespace,
+			Timespan:     *metricsQueryCommandTimespan,
+			Interval:     *metricsQueryCommandInterval,
+			Aggregations: *metricsQueryCommandAggregations,
+			Dimensions:   *metricsQueryCommandDimensions,
+		}
+		processor.processMetricsQueryCommand(*metricsQueryCommandResourceId, metricQuery, *metricsQueryCommandLookback, *metricsCommandOutputFormat)
+		break
+	case "activity":
+		if len(*activityCommandResourceGroup) > 0 && len(*activityCommandResourceId) > 0 {
+			log.Error("Only one of --resourcegroup and --resourceid can be specified")
+			os.Exit(1)
+		}
+
+		start, end, err := parseActivityLogTimeRange(*activityCommandStart, *activityCommandEnd, *activityCommandLookback)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+
+		filter := ActivityLogFilter{
+			Start:         start,
+			End:           end,
+			ResourceGroup: *activityCommandResourceGroup,
+			ResourceId:    *activityCommandResourceId,
+			Caller:        *activityCommandCaller,
+			Status:        *activityCommandStatus,
+		}
+
+		if *activityCommandFollow {
+			processor.processActivityFollowCommand(filter, *activityCommandMaxContinuation, *activityCommandPollInterval, *activityCommandOutputFormat)
+		} else {
+			processor.processActivityCommand(filter, *activityCommandMaxContinuation, *activityCommandOutputFormat)
+		}
+		break
+	case "deploy whatif", "deploy create":
+		scope := DeploymentScope{
+			ManagementGroup: *deployCommandManagementGroup,
+			ResourceGroup:   *deployCommandResourceGroup,
+		}
+
+		// Only subscription and management group deployments have a location and only resource group deployments have a mode
+		location := ""
+		mode := *deployCommandMode
+		if len(scope.ManagementGroup) > 0 || len(scope.ResourceGroup) == 0 {
+			if len(*deployCommandLocation) == 0 {
+				log.Error("--location is required for subscription and management group deployments")
+				os.Exit(1)
+			}
+
+			if mode == "Complete" {
+				log.Error("--mode Complete is only supported for resource group deployments")
+				os.Exit(1)
+			}
+
+			location = *deployCommandLocation
+			mode = "Incremental"
+		}
+
+		deploymentName := *deployCommandName
+		if len(deploymentName) == 0 {
+			deploymentName = "armclient-" + time.Now().UTC().Format("20060102150405")
+		}
+
+		request := newDeploymentRequest(*deployCommandTemplateFile, *deployCommandParametersFile, location, mode)
+		if command == "deploy whatif" {
+			processor.processDeployWhatIfCommand(scope, deploymentName, request, !*deployCommandNoColor)
+		} else {
+			processor.processDeployCreateCommand(scope, deploymentName, request, *deployCreateCommandPreview, *deployCreateCommandYes, !*deployCommandNoColor, *deployCreateCommandPollInterval)
+		}
+		break
+	case "tags audit":
+		processor.processTagsAuditCommand(*tagsCommandMaxContinuation, *tagsCommandFilter, *tagsAuditCommandRuleFile, *tagsAuditCommandOutputFormat, *tagsAuditCommandJUnitFile)
+		break
+	case "tags set":
+		operation := MergeTagOperation
+		if *tagsSetCommandMode == "replace" {
+			operation = ReplaceTagOperation
+		}
+
+		processor.processTagsSetCommand(*tagsCommandMaxContinuation, *tagsCommandFilter, *tagsSetCommandTags, operation, *tagsSetCommandDryRun, *tagsSetCommandConcurrency)
+		break
+	case "tags remove":
+		processor.processTagsRemoveCommand(*tagsCommandMaxContinuation, *tagsCommandFilter, *tagsRemoveCommandKeys, *tagsRemoveCommandDryRun, *tagsRemoveCommandConcurrency)
+		break
+	case "rbac assignments":
+		processor.processRbacAssignmentsCommand(*rbacCommandScope, *rbacAssignmentsCommandMaxContinuation, *rbacCommandOutputFormat)
+		break
+	case "rbac permissions":
+		processor.processRbacPermissionsCommand(*rbacCommandScope, *rbacCommandOutputFormat)
+		break
+	case "policy":
+		processor.processPolicyCommand(*policyCommandResourceGroup, *policyCommandMaxContinuation, *policyCommandOutputFormat)
+		break
+	case "locks list":
+		processor.processLocksListCommand(*locksListCommandScope, *locksCommandMaxContinuation, *locksCommandOutputFormat)
+		break
+	case "locks audit":
+		processor.processLocksAuditCommand(*locksCommandMaxContinuation, *locksAuditCommandFilter, *locksCommandOutputFormat)
+		break
+	case "locks create":
+		processor.processLocksCreateCommand(*locksCreateCommandScope, *locksCreateCommandName, *locksCreateCommandLevel, *locksCreateCommandNotes)
+		break
+	case "locks delete":
+		processor.processLocksDeleteCommand(*locksDeleteCommandScope, *locksDeleteCommandName)
+		break
+	case "groups list":
+		processor.processGroupsListCommand(*groupsListCommandMaxContinuation, *groupsCommandOutputFormat)
+		break
+	case "groups show":
+		processor.processGroupsShowCommand(*groupsShowCommandName, *groupsCommandOutputFormat)
+		break
+	case "groups create":
+		processor.processGroupsCreateCommand(*groupsCreateCommandName, *groupsCreateCommandLocation, *groupsCreateCommandTags)
+		break
+	case "groups delete":
+		processor.processGroupsDeleteCommand(*groupsDeleteCommandName, *groupsDeleteCommandYes)
 		break
-	case "grafana":
-		processor.processGrafanaCommand(*grafanaCommandTitle, *grafanaCommandDataSourceName, *grafanaCommandMaxContinuation, *grafanaCommandMaxDashboardResources, *grafanaCommandResourceType, *grafanaCommandKind, *grafanaCommandSubResourceType, *grafanaCommandSubResourceName)
+	case "groups export":
+		processor.processGroupsExportCommand(*groupsExportCommandName, *groupsExportCommandFile)
 		break
 	default:
 		log.Errorf("Unknown command: %s\n", command)
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+func TestIsListingAzureResources(t *testing.T) {
+	tests := []struct {
+		command                   string
+		isGrafanaVariablesEnabled bool
+		expected                  bool
+	}{
+		{"resources list", false, true},
+		{"grafana validate", false, true},
+		{"grafana generate", false, true},
+		{"grafana generate", true, false},
+		{"groups list", false, false},
+		{"templates sync", false, false},
+	}
+
+	for _, test := range tests {
+		if actual := isListingAzureResources(test.command, test.isGrafanaVariablesEnabled); actual != test.expected {
+			t.Errorf("%s, variables %t: expected %t, got %t", test.command, test.isGrafanaVariablesEnabled, test.expected, actual)
+		}
+	}
+}
+
+func TestCheckFlagsNotSet(t *testing.T) {
+	otherFlags := []string{"--maxdashboardresource", "--layout", "--group-by"}
+	tests := []struct {
+		isOtherFlagSet []bool
+		expectedError  string
+	}{
+		{[]bool{false, false, false}, ""},
+		{[]bool{false, true, false}, "--variables cannot be combined with --layout"},
+		{[]bool{true, false, true}, "--variables cannot be combined with --maxdashboardresource, --group-by"},
+	}
+
+	for _, test := range tests {
+		err := checkFlagsNotSet("--variables", otherFlags, test.isOtherFlagSet)
+		if len(test.expectedError) == 0 && err != nil {
+			t.Errorf("%v: unexpected error %v", test.isOtherFlagSet, err)
+		}
+
+		if len(test.expectedError) > 0 && (err == nil || err.Error() != test.expectedError) {
+			t.Errorf("%v: expected error %q, got %v", test.isOtherFlagSet, test.expectedError, err)
+		}
+	}
+}
+
+func TestSetByUser(t *testing.T) {
+	tests := []struct {
+		args          []string
+		expectedIsSet bool
+	}{
+		{[]string{"generate"}, false},
+		{[]string{"generate", "--layout", "targets"}, true},
+		{[]string{"generate", "--layout=panels"}, true},
+	}
+
+	for _, test := range tests {
+		var isLayoutSet bool
+		app := kingpin.New("armclient", "")
+		app.Command("generate", "").Flag("layout", "").Default(TargetsGrafanaLayout).Action(setByUser(&isLayoutSet)).Enum(GrafanaLayouts...)
+		if _, err := app.Parse(test.args); err != nil {
+			t.Fatalf("%v: %v", test.args, err)
+		}
+
+		if isLayoutSet != test.expectedIsSet {
+			t.Errorf("%v: expected set %t", test.args, test.expectedIsSet)
+		}
+	}
+}
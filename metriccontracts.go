@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	MetricsApiVersion = "2018-01-01"
+)
+
+type LocalizableString struct {
+	Value          string `json:"value"`
+	LocalizedValue string `json:"localizedValue"`
+}
+
+type MetricDefinition struct {
+	Id                        string               `json:"id"`
+	ResourceId                string               `json:"resourceId"`
+	Namespace                 string               `json:"namespace"`
+	Name                      LocalizableString    `json:"name"`
+	Unit                      string               `json:"unit"`
+	PrimaryAggregationType    string               `json:"primaryAggregationType"`
+	SupportedAggregationTypes []string             `json:"supportedAggregationTypes"`
+	MetricAvailabilities      []MetricAvailability `json:"metricAvailabilities"`
+	Dimensions                []LocalizableString  `json:"dimensions"`
+	IsDimensionRequired       bool                 `json:"isDimensionRequired"`
+}
+
+type MetricAvailability struct {
+	TimeGrain string `json:"timeGrain"`
+	Retention string `json:"retention"`
+}
+
+type MetricQuery struct {
+	MetricNames  []string
+	Namespace    string
+	Timespan     string
+	Interval     string
+	Aggregations []string
+	Dimensions   []string
+}
+
+type MetricResponse struct {
+	Cost     float64  `json:"cost"`
+	Timespan string   `json:"timespan"`
+	Interval string   `json:"interval"`
+	Value    []Metric `json:"value"`
+}
+
+type Metric struct {
+	Id         string             `json:"id"`
+	Name       LocalizableString  `json:"name"`
+	Unit       string             `json:"unit"`
+	Timeseries []MetricTimeseries `json:"timeseries"`
+}
+
+type MetricTimeseries struct {
+	MetadataValues []MetricMetadataValue `json:"metadatavalues"`
+	Data           []MetricValue         `json:"data"`
+}
+
+type MetricMetadataValue struct {
+	Name  LocalizableString `json:"name"`
+	Value string            `json:"value"`
+}
+
+type MetricValue struct {
+	TimeStamp string   `json:"timeStamp"`
+	Average   *float64 `json:"average,omitempty"`
+	Minimum   *float64 `json:"minimum,omitempty"`
+	Maximum   *float64 `json:"maximum,omitempty"`
+	Total     *float64 `json:"total,omitempty"`
+	Count     *float64 `json:"count,omitempty"`
+}
+
+func (azureClient *AzureClient) getMetricDefinitions(resourceId string, metricNamespace string) []MetricDefinition {
+	metricDefinitions, err := azureClient.tryGetMetricDefinitions(resourceId, metricNamespace)
+	if err != nil {
+		log.Fatalf("Error getting metric definitions: %v", err)
+	}
+
+	return metricDefinitions
+}
+
+// Get the metric definitions, returning an error instead of exiting when the API call fails, e.g. for an unknown metric namespace
+func (azureClient *AzureClient) tryGetMetricDefinitions(resourceId string, metricNamespace string) ([]MetricDefinition, error) {
+	query := url.Values{"api-version": {MetricsApiVersion}}
+	if len(metricNamespace) > 0 {
+		query.Set("metricnamespace", metricNamespace)
+	}
+
+	targetUrl := fmt.Sprintf("%s/providers/microsoft.insights/metricDefinitions?%s", normalizeResourceId(resourceId), query.Encode())
+
+	body, err := azureClient.tryGetJsonResponseBody("GET", targetUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var armListResponse ArmListResponse
+	err = json.Unmarshal(body, &armListResponse)
+	if err != nil {
+		return nil, fmt.Errorf("Error unmarshalling metric definitions response body: %v", err)
+	}
+
+	metricDefinitions := make([]MetricDefinition, 0)
+	for _, value := range armListResponse.Values {
+		var metricDefinition MetricDefinition
+		err := json.Unmarshal(value, &metricDefinition)
+		if err != nil {
+			return nil, fmt.Errorf("Error unmarshalling metric definition response body: %v", err)
+		}
+
+		metricDefinitions = append(metricDefinitions, metricDefinition)
+	}
+
+	return metricDefinitions, nil
+}
+
+func (azureClient *AzureClient) getMetrics(resourceId string, metricQuery MetricQuery) MetricResponse {
+	query := url.Values{
+		"api-version": {MetricsApiVersion},
+		"metricnames": {strings.Join(metricQuery.MetricNames, ",")},
+	}
+
+	if len(metricQuery.Namespace) > 0 {
+		query.Set("metricnamespace", metricQuery.Namespace)
+	}
+
+	if len(metricQuery.Timespan) > 0 {
+		query.Set("timespan", metricQuery.Timespan)
+	}
+
+	if len(metricQuery.Interval) > 0 {
+		query.Set("interval", metricQuery.Interval)
+	}
+
+	if len(metricQuery.Aggregations) > 0 {
+		query.Set("aggregation", strings.Join(metricQuery.Aggregations, ","))
+	}
+
+	if filter := metricQuery.getDimensionFilter(); len(filter) > 0 {
+		query.Set("$filter", filter)
+	}
+
+	targetUrl := fmt.Sprintf("%s/providers/microsoft.insights/metrics?%s", normalizeResourceId(resourceId), query.Encode())
+
+	var metricResponse MetricResponse
+	err := json.Unmarshal(azureClient.getResponseBody("GET", targetUrl), &metricResponse)
+	if err != nil {
+		log.Fatalf("Error unmarshalling metric response body: %v", err)
+	}
+
+	return metricResponse
+}
+
+// Convert the dimension filters in the form of {name}={value} into the OData filter expected by the metrics API.
+// A dimension without value (or with value *) splits the series by that dimension.
+func (metricQuery *MetricQuery) getDimensionFilter() string {
+	filters := make([]string, 0)
+	for _, dimension := range metricQuery.Dimensions {
+		dimensionParts := strings.SplitN(dimension, "=", 2)
+		dimensionValue := "*"
+		if len(dimensionParts) == 2 && len(dimensionParts[1]) > 0 {
+			dimensionValue = dimensionParts[1]
+		}
+
+		filters = append(filters, fmt.Sprintf("%s eq '%s'", dimensionParts[0], strings.Replace(dimensionValue, "'", "''", -1)))
+	}
+
+	return strings.Join(filters, " and ")
+}
+
+// Generate the ISO 8601 timespan {start}/{end} ending now
+func getMetricTimespan(lookback time.Duration) string {
+	end := time.Now().UTC()
+	start := end.Add(-lookback)
+	return fmt.Sprintf("%s/%s", start.Format(time.RFC3339), end.Format(time.RFC3339))
+}
+
+func (metricDefinition *MetricDefinition) getDimensionNames() []string {
+	dimensionNames := make([]string, 0)
+	for _, dimension := range metricDefinition.Dimensions {
+		dimensionNames = append(dimensionNames, dimension.Value)
+	}
+
+	return dimensionNames
+}
+
+func (metricDefinition *MetricDefinition) getTimeGrains() []string {
+	timeGrains := make([]string, 0)
+	for _, metricAvailability := range metricDefinition.MetricAvailabilities {
+		timeGrains = append(timeGrains, metricAvailability.TimeGrain)
+	}
+
+	return timeGrains
+}
+
+func (timeseries *MetricTimeseries) getDimensionValues() string {
+	dimensionValues := make([]string, 0)
+	for _, metadataValue := range timeseries.MetadataValues {
+		dimensionValues = append(dimensionValues, metadataValue.Name.Value+"="+metadataValue.Value)
+	}
+
+	return strings.Join(dimensionValues, ", ")
+}
+
+func getMetricDefinitionsOutputTable(metricDefinitions []MetricDefinition) OutputTable {
+	table := OutputTable{
+		Headers: []string{"Name", "Namespace", "Unit", "PrimaryAggregation", "SupportedAggregations", "Dimensions", "TimeGrains"},
+	}
+
+	for _, metricDefinition := range metricDefinitions {
+		table.addRow(
+			metricDefinition.Name.Value,
+			metricDefinition.Namespace,
+			metricDefinition.Unit,
+			metricDefinition.PrimaryAggregationType,
+			strings.Join(metricDefinition.SupportedAggregationTypes, ", "),
+			strings.Join(metricDefinition.getDimensionNames(), ", "),
+			strings.Join(metricDefinition.getTimeGrains(), ", "),
+		)
+	}
+
+	return table
+}
+
+func getMetricsOutputTable(metricResponse MetricResponse) OutputTable {
+	table := OutputTable{
+		Headers: []string{"Metric", "Unit", "Dimensions", "TimeStamp", "Average", "Minimum", "Maximum", "Total", "Count"},
+	}
+
+	for _, metric := range metricResponse.Value {
+		for _, timeseries := range metric.Timeseries {
+			dimensionValues := timeseries.getDimensionValues()
+			for _, metricValue := range timeseries.Data {
+				table.addRow(
+					metric.Name.Value,
+					metric.Unit,
+					dimensionValues,
+					metricValue.TimeStamp,
+					formatMetricValue(metricValue.Average),
+					formatMetricValue(metricValue.Minimum),
+					formatMetricValue(metricValue.Maximum),
+					formatMetricValue(metricValue.Total),
+					formatMetricValue(metricValue.Count),
+				)
+			}
+		}
+	}
+
+	return table
+}
+
+func formatMetricValue(value *float64) string {
+	if value == nil {
+		return ""
+	}
+
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
+
+func normalizeResourceId(resourceId string) string {
+	resourceId = strings.TrimSuffix(resourceId, "/")
+	if !strings.HasPrefix(resourceId, "/") {
+		resourceId = "/" + resourceId
+	}
+
+	return resourceId
+}
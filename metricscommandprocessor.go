@@ -0,0 +1,19 @@
+package main
+
+import (
+	"time"
+)
+
+func (processor *CommandProcessor) processMetricsDefinitionsCommand(resourceId string, metricNamespace string, outputFormat string) {
+	metricDefinitions := processor.azureClient.getMetricDefinitions(resourceId, metricNamespace)
+	printOutput(outputFormat, getMetricDefinitionsOutputTable(metricDefinitions), metricDefinitions)
+}
+
+func (processor *CommandProcessor) processMetricsQueryCommand(resourceId string, metricQuery MetricQuery, lookback time.Duration, outputFormat string) {
+	if len(metricQuery.Timespan) == 0 {
+		metricQuery.Timespan = getMetricTimespan(lookback)
+	}
+
+	metricResponse := processor.azureClient.getMetrics(resourceId, metricQuery)
+	printOutput(outputFormat, getMetricsOutputTable(metricResponse), metricResponse)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	TextOutputFormat = "text"
+	JsonOutputFormat = "json"
+	CsvOutputFormat  = "csv"
+)
+
+var OutputFormats = []string{TextOutputFormat, JsonOutputFormat, CsvOutputFormat}
+
+// Tabular representation of command output, used for the text and csv output formats
+type OutputTable struct {
+	Headers []string
+	Rows    [][]string
+}
+
+func (table *OutputTable) addRow(columns ...string) {
+	table.Rows = append(table.Rows, columns)
+}
+
+// Print the command output in the given format.  The value is serialized as-is for the json output format.
+func printOutput(outputFormat string, table OutputTable, value interface{}) {
+	writeOutput(os.Stdout, outputFormat, table, value)
+}
+
+// Write the command output in the given format.  Only the output is written, logs go to stderr.
+func writeOutput(writer io.Writer, outputFormat string, table OutputTable, value interface{}) {
+	switch outputFormat {
+	case JsonOutputFormat:
+		writeJson(writer, value)
+	case CsvOutputFormat:
+		writeCsv(writer, table)
+	default:
+		writeTable(writer, table)
+	}
+}
+
+// Print a batch of streamed command output, e.g. for --follow modes.  The headers are only printed with the first batch
+// and json values are printed one per line.
+func printOutputBatch(outputFormat string, table OutputTable, values []interface{}, isFirstBatch bool) {
+	switch outputFormat {
+	case JsonOutputFormat:
+		for _, value := range values {
+			valueJson, err := json.Marshal(value)
+			if err != nil {
+				log.Fatalf("Error generating JSON output: %v", err)
+			}
+
+			fmt.Println(string(valueJson))
+		}
+	default:
+		if !isFirstBatch {
+			table.Headers = nil
+		}
+
+		if outputFormat == CsvOutputFormat {
+			printCsv(table)
+		} else {
+			printTable(table)
+		}
+	}
+}
+
+func printJson(value interface{}) {
+	writeJson(os.Stdout, value)
+}
+
+func writeJson(writer io.Writer, value interface{}) {
+	prettyPrint, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		log.Fatalf("Error generating JSON output: %v", err)
+	}
+
+	fmt.Fprintln(writer, string(prettyPrint))
+}
+
+func printCsv(table OutputTable) {
+	writeCsv(os.Stdout, table)
+}
+
+func writeCsv(output io.Writer, table OutputTable) {
+	writer := csv.NewWriter(output)
+	if len(table.Headers) > 0 {
+		writer.Write(table.Headers)
+	}
+
+	writer.WriteAll(table.Rows)
+
+	if err := writer.Error(); err != nil {
+		log.Fatalf("Error generating CSV output: %v", err)
+	}
+}
+
+func printTable(table OutputTable) {
+	writeTable(os.Stdout, table)
+}
+
+func writeTable(output io.Writer, table OutputTable) {
+	writer := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	if len(table.Headers) > 0 {
+		fmt.Fprintln(writer, strings.Join(table.Headers, "\t"))
+	}
+
+	for _, row := range table.Rows {
+		fmt.Fprintln(writer, strings.Join(row, "\t"))
+	}
+
+	writer.Flush()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestWriteOutputParses(t *testing.T) {
+	table := OutputTable{Headers: []string{"Id", "Name"}}
+	table.addRow("/subscriptions/sub1/resourceGroups/rg/providers/A/b/c", "c, with comma")
+	table.addRow("/subscriptions/sub1/resourceGroups/rg/providers/A/b/d", "d \"quoted\"")
+	value := []map[string]string{{"id": "c"}, {"id": "d"}}
+
+	tests := []struct {
+		outputFormat string
+		parse        func(output []byte) (interface{}, error)
+		expected     interface{}
+	}{
+		{
+			outputFormat: JsonOutputFormat,
+			parse: func(output []byte) (interface{}, error) {
+				var parsed []map[string]string
+				err := json.Unmarshal(output, &parsed)
+				return parsed, err
+			},
+			expected: value,
+		},
+		{
+			outputFormat: CsvOutputFormat,
+			parse: func(output []byte) (interface{}, error) {
+				return csv.NewReader(bytes.NewReader(output)).ReadAll()
+			},
+			expected: append([][]string{table.Headers}, table.Rows...),
+		},
+	}
+
+	for _, test := range tests {
+		var output bytes.Buffer
+		writeOutput(&output, test.outputFormat, table, value)
+
+		parsed, err := test.parse(output.Bytes())
+		if err != nil {
+			t.Fatalf("%s output does not parse: %v\n%s", test.outputFormat, err, output.String())
+		}
+
+		if !reflect.DeepEqual(parsed, test.expected) {
+			t.Errorf("%s output: expected %v, got %v", test.outputFormat, test.expected, parsed)
+		}
+	}
+}
+
+func TestLogsGoToStderr(t *testing.T) {
+	defer initLogging(false)
+
+	for _, isDebugEnabled := range []bool{false, true} {
+		initLogging(isDebugEnabled)
+		if log.StandardLogger().Out != os.Stderr {
+			t.Errorf("debug %t: logs are not written to stderr", isDebugEnabled)
+		}
+	}
+}
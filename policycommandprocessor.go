@@ -0,0 +1,17 @@
+package main
+
+// Show the non-compliant resources of the subscription or resource group grouped by policy assignment and definition
+func (processor *CommandProcessor) processPolicyCommand(resourceGroup string, maxContinuation int, outputFormat string) {
+	scope := processor.azureClient.getPolicyScope(resourceGroup)
+
+	summary := processor.azureClient.summarizePolicyStates(scope)
+	policyStates := processor.azureClient.getNonCompliantPolicyStates(scope, maxContinuation)
+	armResources := processor.azureClient.getAzureResources(maxContinuation)
+
+	policyAssignments := groupPolicyStates(summary, policyStates, armResources)
+	if outputFormat == TextOutputFormat {
+		printPolicyAssignmentCompliances(summary, policyAssignments)
+	} else {
+		printOutput(outputFormat, getPolicyAssignmentCompliancesOutputTable(policyAssignments), policyAssignments)
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PolicyInsightsApiVersion = "2019-10-01"
+)
+
+type PolicySummaryResponse struct {
+	Values []PolicySummary `json:"value"`
+}
+
+type PolicySummary struct {
+	Results           PolicySummaryResults      `json:"results"`
+	PolicyAssignments []PolicyAssignmentSummary `json:"policyAssignments"`
+}
+
+type PolicySummaryResults struct {
+	NonCompliantResources int `json:"nonCompliantResources"`
+	NonCompliantPolicies  int `json:"nonCompliantPolicies"`
+}
+
+type PolicyAssignmentSummary struct {
+	PolicyAssignmentId    string                    `json:"policyAssignmentId"`
+	PolicySetDefinitionId string                    `json:"policySetDefinitionId"`
+	Results               PolicySummaryResults      `json:"results"`
+	PolicyDefinitions     []PolicyDefinitionSummary `json:"policyDefinitions"`
+}
+
+type PolicyDefinitionSummary struct {
+	PolicyDefinitionId string               `json:"policyDefinitionId"`
+	Effect             string               `json:"effect"`
+	Results            PolicySummaryResults `json:"results"`
+}
+
+type PolicyStatesResponse struct {
+	Values        []PolicyState `json:"value"`
+	ODataNextLink string        `json:"@odata.nextLink"`
+}
+
+type PolicyState struct {
+	ResourceId                  string `json:"resourceId"`
+	ResourceType                string `json:"resourceType"`
+	ResourceLocation            string `json:"resourceLocation"`
+	ResourceGroup               string `json:"resourceGroup"`
+	ComplianceState             string `json:"complianceState"`
+	PolicyAssignmentId          string `json:"policyAssignmentId"`
+	PolicyAssignmentName        string `json:"policyAssignmentName"`
+	PolicyDefinitionId          string `json:"policyDefinitionId"`
+	PolicyDefinitionName        string `json:"policyDefinitionName"`
+	PolicyDefinitionAction      string `json:"policyDefinitionAction"`
+	PolicyDefinitionReferenceId string `json:"policyDefinitionReferenceId"`
+}
+
+// Non-compliant resources of a policy assignment, grouped by policy definition
+type PolicyAssignmentCompliance struct {
+	PolicyAssignmentId    string                       `json:"policyAssignmentId"`
+	PolicyAssignmentName  string                       `json:"policyAssignmentName"`
+	NonCompliantResources int                          `json:"nonCompliantResources"`
+	PolicyDefinitions     []PolicyDefinitionCompliance `json:"policyDefinitions"`
+}
+
+type PolicyDefinitionCompliance struct {
+	PolicyDefinitionId   string                    `json:"policyDefinitionId"`
+	PolicyDefinitionName string                    `json:"policyDefinitionName"`
+	Effect               string                    `json:"effect"`
+	Resources            []NonCompliantArmResource `json:"resources"`
+}
+
+type NonCompliantArmResource struct {
+	Id       string `json:"id"`
+	Type     string `json:"type"`
+	Location string `json:"location"`
+}
+
+func (azureClient *AzureClient) getPolicyScope(resourceGroup string) string {
+	scope := azureClient.getSubscriptionScope()
+	if len(resourceGroup) > 0 {
+		scope += "/resourceGroups/" + resourceGroup
+	}
+
+	return scope
+}
+
+func (azureClient *AzureClient) summarizePolicyStates(scope string) PolicySummary {
+	targetUrl := fmt.Sprintf("%s/providers/Microsoft.PolicyInsights/policyStates/latest/summarize?api-version=%s", scope, PolicyInsightsApiVersion)
+
+	var summaryResponse PolicySummaryResponse
+	err := json.Unmarshal(azureClient.getResponseBody("POST", targetUrl), &summaryResponse)
+	if err != nil {
+		log.Fatalf("Error unmarshalling policy summary response body: %v", err)
+	}
+
+	if len(summaryResponse.Values) == 0 {
+		return PolicySummary{}
+	}
+
+	return summaryResponse.Values[0]
+}
+
+// Query the latest non-compliant policy states, following @odata.nextLink continuation tokens.  The query API is a POST,
+// including for the continuations.
+func (azureClient *AzureClient) getNonCompliantPolicyStates(scope string, maxContinuation int) []PolicyState {
+	query := url.Values{
+		"api-version": {PolicyInsightsApiVersion},
+		"$filter":     {"complianceState eq 'NonCompliant'"},
+	}
+
+	targetUrl := fmt.Sprintf("%s/providers/Microsoft.PolicyInsights/policyStates/latest/queryResults?%s", scope, query.Encode())
+
+	policyStates := make([]PolicyState, 0)
+	for i := 0; len(targetUrl) > 0 && i <= maxContinuation; i++ {
+		var statesResponse PolicyStatesResponse
+		err := json.Unmarshal(azureClient.getResponseBody("POST", targetUrl), &statesResponse)
+		if err != nil {
+			log.Fatalf("Error unmarshalling policy states response body: %v", err)
+		}
+
+		policyStates = append(policyStates, statesResponse.Values...)
+		targetUrl = statesResponse.ODataNextLink
+	}
+
+	return policyStates
+}
+
+// Group the non-compliant policy states by policy assignment and definition.  The resource type and location are
+// taken from the ARM resources where available, since policy states may lag behind the resources.
+func groupPolicyStates(summary PolicySummary, policyStates []PolicyState, armResources []ArmResource) []PolicyAssignmentCompliance {
+	armResourcesById := make(map[string]ArmResource)
+	for _, armResource := range armResources {
+		armResourcesById[strings.ToLower(armResource.Id)] = armResource
+	}
+
+	effects := make(map[string]string)
+	for _, assignmentSummary := range summary.PolicyAssignments {
+		for _, definitionSummary := range assignmentSummary.PolicyDefinitions {
+			effects[strings.ToLower(assignmentSummary.PolicyAssignmentId+"|"+definitionSummary.PolicyDefinitionId)] = definitionSummary.Effect
+		}
+	}
+
+	assignments := make(map[string]*PolicyAssignmentCompliance)
+	definitions := make(map[string]*PolicyDefinitionCompliance)
+	assignmentResources := make(map[string]map[string]bool)
+	assignmentOrder := make([]string, 0)
+	definitionOrder := make(map[string][]string)
+
+	for _, policyState := range policyStates {
+		assignmentKey := strings.ToLower(policyState.PolicyAssignmentId)
+		assignment, ok := assignments[assignmentKey]
+		if !ok {
+			assignment = &PolicyAssignmentCompliance{
+				PolicyAssignmentId:   policyState.PolicyAssignmentId,
+				PolicyAssignmentName: policyState.PolicyAssignmentName,
+			}
+			assignments[assignmentKey] = assignment
+			assignmentResources[assignmentKey] = make(map[string]bool)
+			assignmentOrder = append(assignmentOrder, assignmentKey)
+		}
+
+		definitionKey := strings.ToLower(policyState.PolicyAssignmentId + "|" + policyState.PolicyDefinitionId)
+		definition, ok := definitions[definitionKey]
+		if !ok {
+			definition = &PolicyDefinitionCompliance{
+				PolicyDefinitionId:   policyState.PolicyDefinitionId,
+				PolicyDefinitionName: policyState.PolicyDefinitionName,
+				Effect:               effects[definitionKey],
+				Resources:            make([]NonCompliantArmResource, 0),
+			}
+			definitions[definitionKey] = definition
+			definitionOrder[assignmentKey] = append(definitionOrder[assignmentKey], definitionKey)
+		}
+
+		resource := NonCompliantArmResource{
+			Id:       policyState.ResourceId,
+			Type:     policyState.ResourceType,
+			Location: policyState.ResourceLocation,
+		}
+
+		if armResource, ok := armResourcesById[strings.ToLower(policyState.ResourceId)]; ok {
+			resource.Type = armResource.Type
+			resource.Location = armResource.Location
+		}
+
+		definition.Resources = append(definition.Resources, resource)
+		assignmentResources[assignmentKey][strings.ToLower(policyState.ResourceId)] = true
+	}
+
+	policyAssignments := make([]PolicyAssignmentCompliance, 0)
+	for _, assignmentKey := range assignmentOrder {
+		assignment := assignments[assignmentKey]
+		assignment.NonCompliantResources = len(assignmentResources[assignmentKey])
+		for _, definitionKey := range definitionOrder[assignmentKey] {
+			assignment.PolicyDefinitions = append(assignment.PolicyDefinitions, *definitions[definitionKey])
+		}
+
+		policyAssignments = append(policyAssignments, *assignment)
+	}
+
+	sort.SliceStable(policyAssignments, func(i, j int) bool {
+		return policyAssignments[i].NonCompliantResources > policyAssignments[j].NonCompliantResources
+	})
+
+	return policyAssignments
+}
+
+func getPolicyAssignmentCompliancesOutputTable(policyAssignments []PolicyAssignmentCompliance) OutputTable {
+	table := OutputTable{
+		Headers: []string{"PolicyAssignment", "PolicyDefinition", "Effect", "ResourceId", "ResourceType", "Location"},
+	}
+
+	for _, assignment := range policyAssignments {
+		for _, definition := range assignment.PolicyDefinitions {
+			for _, resource := range definition.Resources {
+				table.addRow(assignment.PolicyAssignmentName, definition.PolicyDefinitionName, definition.Effect, resource.Id, resource.Type, resource.Location)
+			}
+		}
+	}
+
+	return table
+}
+
+func printPolicyAssignmentCompliances(summary PolicySummary, policyAssignments []PolicyAssignmentCompliance) {
+	fmt.Printf("Non-compliant resources: %d\n", summary.Results.NonCompliantResources)
+	fmt.Printf("Non-compliant policies: %d\n\n", summary.Results.NonCompliantPolicies)
+
+	for _, assignment := range policyAssignments {
+		fmt.Printf("Assignment: %s (%d non-compliant resources):\n", assignment.PolicyAssignmentName, assignment.NonCompliantResources)
+		for _, definition := range assignment.PolicyDefinitions {
+			fmt.Printf("  Definition: %s", definition.PolicyDefinitionName)
+			if len(definition.Effect) > 0 {
+				fmt.Printf(" (%s)", definition.Effect)
+			}
+
+			fmt.Println(":")
+			for _, resource := range definition.Resources {
+				fmt.Printf("    Id: %s (%s, %s)\n", resource.Id, resource.Type, resource.Location)
+			}
+		}
+
+		fmt.Println()
+	}
+}
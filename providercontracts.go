@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type ArmProvider struct {
+	Id                string                    `json:"id"`
+	Namespace         string                    `json:"namespace"`
+	RegistrationState string                    `json:"registrationState"`
+	ResourceTypes     []ArmProviderResourceType `json:"resourceTypes"`
+}
+
+type ArmProviderResourceType struct {
+	ResourceType string   `json:"resourceType"`
+	Locations    []string `json:"locations"`
+	ApiVersions  []string `json:"apiVersions"`
+}
+
+// Flattened view of a resource type, qualified with its provider namespace (e.g. Microsoft.Storage/storageAccounts)
+type ArmResourceTypeInfo struct {
+	Type        string   `json:"type"`
+	Locations   []string `json:"locations"`
+	ApiVersions []string `json:"apiVersions"`
+}
+
+func (azureClient *AzureClient) getProviders(maxContinuation int) []ArmProvider {
+	targetUrl := fmt.Sprintf(
+		"/subscriptions/%s/providers?api-version=%s",
+		azureClient.config.Credentials.SubscriptionID,
+		azureClient.environment.apiVersion,
+	)
+
+	providers := make([]ArmProvider, 0)
+	for _, value := range azureClient.getPagedValues(targetUrl, maxContinuation) {
+		providers = append(providers, convertToArmProvider(value))
+	}
+
+	return providers
+}
+
+func (azureClient *AzureClient) getProvider(namespace string) ArmProvider {
+	targetUrl := fmt.Sprintf(
+		"/subscriptions/%s/providers/%s?api-version=%s",
+		azureClient.config.Credentials.SubscriptionID,
+		namespace,
+		azureClient.environment.apiVersion,
+	)
+
+	return convertToArmProvider(azureClient.getResponseBody("GET", targetUrl))
+}
+
+// Register or unregister the subscription with the provider namespace.  The action is either "register" or "unregister".
+func (azureClient *AzureClient) updateProviderRegistration(namespace string, action string) ArmProvider {
+	targetUrl := fmt.Sprintf(
+		"/subscriptions/%s/providers/%s/%s?api-version=%s",
+		azureClient.config.Credentials.SubscriptionID,
+		namespace,
+		action,
+		azureClient.environment.apiVersion,
+	)
+
+	return convertToArmProvider(azureClient.getResponseBody("POST", targetUrl))
+}
+
+func (provider *ArmProvider) getResourceTypeInfos() []ArmResourceTypeInfo {
+	resourceTypeInfos := make([]ArmResourceTypeInfo, 0)
+	for _, resourceType := range provider.ResourceTypes {
+		resourceTypeInfos = append(resourceTypeInfos, ArmResourceTypeInfo{
+			Type:        provider.Namespace + "/" + resourceType.ResourceType,
+			Locations:   resourceType.Locations,
+			ApiVersions: resourceType.ApiVersions,
+		})
+	}
+
+	return resourceTypeInfos
+}
+
+// Returns true if the resource type is available in the given location.  Location comparison ignores case and spaces
+// since ARM returns display names such as "West US" while resources carry "westus".
+func (resourceTypeInfo *ArmResourceTypeInfo) isAvailableInLocation(location string) bool {
+	normalizedLocation := normalizeLocation(location)
+	for _, availableLocation := range resourceTypeInfo.Locations {
+		if normalizeLocation(availableLocation) == normalizedLocation {
+			return true
+		}
+	}
+
+	return false
+}
+
+func normalizeLocation(location string) string {
+	return strings.ToLower(strings.Replace(location, " ", "", -1))
+}
+
+func getProvidersOutputTable(providers []ArmProvider) OutputTable {
+	table := OutputTable{
+		Headers: []string{"Namespace", "RegistrationState", "ResourceTypes"},
+	}
+
+	for _, provider := range providers {
+		table.addRow(provider.Namespace, provider.RegistrationState, fmt.Sprintf("%d", len(provider.ResourceTypes)))
+	}
+
+	return table
+}
+
+func getResourceTypeInfosOutputTable(resourceTypeInfos []ArmResourceTypeInfo) OutputTable {
+	table := OutputTable{
+		Headers: []string{"Type", "ApiVersions", "Locations"},
+	}
+
+	for _, resourceTypeInfo := range resourceTypeInfos {
+		table.addRow(resourceTypeInfo.Type, strings.Join(resourceTypeInfo.ApiVersions, ", "), strings.Join(resourceTypeInfo.Locations, ", "))
+	}
+
+	return table
+}
+
+func convertToArmProvider(body []byte) ArmProvider {
+	var provider ArmProvider
+	err := json.Unmarshal(body, &provider)
+	if err != nil {
+		log.Fatalf("Error unmarshalling ARM provider response body: %v", err)
+	}
+
+	return provider
+}
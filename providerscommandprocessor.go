@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+)
+
+func (processor *CommandProcessor) processProvidersListCommand(maxContinuation int, outputFormat string) {
+	providers := processor.azureClient.getProviders(maxContinuation)
+	printOutput(outputFormat, getProvidersOutputTable(providers), providers)
+}
+
+func (processor *CommandProcessor) processProvidersShowCommand(namespace string, outputFormat string) {
+	provider := processor.azureClient.getProvider(namespace)
+
+	if outputFormat == TextOutputFormat {
+		fmt.Printf("Namespace: %s\n", provider.Namespace)
+		fmt.Printf("RegistrationState: %s\n\n", provider.RegistrationState)
+	}
+
+	resourceTypeInfos := provider.getResourceTypeInfos()
+	if outputFormat == JsonOutputFormat {
+		printOutput(outputFormat, OutputTable{}, provider)
+	} else {
+		printOutput(outputFormat, getResourceTypeInfosOutputTable(resourceTypeInfos), resourceTypeInfos)
+	}
+}
+
+func (processor *CommandProcessor) processProvidersTypesCommand(maxContinuation int, namespace string, location string, outputFormat string) {
+	var providers []ArmProvider
+	if len(namespace) > 0 {
+		providers = []ArmProvider{processor.azureClient.getProvider(namespace)}
+	} else {
+		providers = processor.azureClient.getProviders(maxContinuation)
+	}
+
+	resourceTypeInfos := make([]ArmResourceTypeInfo, 0)
+	for _, provider := range providers {
+		for _, resourceTypeInfo := range provider.getResourceTypeInfos() {
+			if len(location) > 0 && !resourceTypeInfo.isAvailableInLocation(location) {
+				continue
+			}
+
+			resourceTypeInfos = append(resourceTypeInfos, resourceTypeInfo)
+		}
+	}
+
+	printOutput(outputFormat, getResourceTypeInfosOutputTable(resourceTypeInfos), resourceTypeInfos)
+}
+
+func (processor *CommandProcessor) processProvidersRegistrationCommand(namespace string, action string) {
+	provider := processor.azureClient.updateProviderRegistration(namespace, action)
+	fmt.Printf("%s: %s\n", provider.Namespace, provider.RegistrationState)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func (processor *CommandProcessor) processRbacAssignmentsCommand(scope string, maxContinuation int, outputFormat string) {
+	if len(scope) == 0 {
+		scope = processor.azureClient.getSubscriptionScope()
+	}
+
+	roleAssignments := processor.azureClient.getRoleAssignments(scope, maxContinuation)
+	roleAssignmentInfos := processor.azureClient.getRoleAssignmentInfos(roleAssignments)
+	printOutput(outputFormat, getRoleAssignmentInfosOutputTable(roleAssignmentInfos), roleAssignmentInfos)
+}
+
+func (processor *CommandProcessor) processRbacPermissionsCommand(scope string, outputFormat string) {
+	if len(scope) == 0 {
+		scope = processor.azureClient.getSubscriptionScope()
+	}
+
+	permissions := processor.azureClient.getPermissions(scope)
+	if outputFormat == TextOutputFormat {
+		fmt.Printf("Effective permissions of client %s on %s:\n\n", processor.azureClient.config.Credentials.ClientID, scope)
+	}
+
+	printOutput(outputFormat, getPermissionsOutputTable(permissions), permissions)
+
+	if outputFormat == TextOutputFormat {
+		fmt.Printf("\nCan read resources: %t\n", isActionAllowed(permissions, ReadResourcesAction))
+	}
+}
+
+// Warn when the configured principal cannot read the resources on the subscription, which otherwise shows up as an
+// empty resource list.  The check never fails the command.
+func (processor *CommandProcessor) checkReadAccess() {
+	permissions, err := processor.azureClient.tryGetPermissions(processor.azureClient.getSubscriptionScope())
+	if err != nil {
+		log.Warnf("Unable to check the permissions of client %s: %v", processor.azureClient.config.Credentials.ClientID, err)
+		return
+	}
+
+	if !isActionAllowed(permissions, ReadResourcesAction) {
+		log.Warnf(
+			"Client %s lacks %s on subscription %s.  Assign it the Reader role.",
+			processor.azureClient.config.Credentials.ClientID,
+			ReadResourcesAction,
+			processor.azureClient.config.Credentials.SubscriptionID,
+		)
+	}
+}
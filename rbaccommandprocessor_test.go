@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestCheckReadAccess(t *testing.T) {
+	defer initLogging(false)
+	initLogging(false)
+
+	tests := []struct {
+		statusCode      int
+		body            string
+		expectedWarning string
+	}{
+		{200, `{"value":[{"actions":["*/read"],"notActions":[]}]}`, ""},
+		{200, `{"value":[{"actions":["Microsoft.Storage/*"],"notActions":[]}]}`, "lacks Microsoft.Resources/subscriptions/resources/read"},
+		{403, `{"error":{"code":"AuthorizationFailed","message":"The client does not have authorization."}}`, "Unable to check the permissions of client client1: status code 403: The client does not have authorization."},
+		{500, `not json`, "Unable to check the permissions of client client1: status code 500"},
+	}
+
+	for _, test := range tests {
+		azureClient := newTestAzureClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.statusCode)
+			w.Write([]byte(test.body))
+		})
+		azureClient.config.Credentials.ClientID = "client1"
+
+		var logOutput bytes.Buffer
+		log.SetOutput(&logOutput)
+		processor := &CommandProcessor{azureClient: azureClient}
+		processor.checkReadAccess()
+
+		if len(test.expectedWarning) == 0 && logOutput.Len() > 0 {
+			t.Errorf("%d %s: unexpected log output %s", test.statusCode, test.body, logOutput.String())
+		}
+
+		if len(test.expectedWarning) > 0 && !strings.Contains(logOutput.String(), test.expectedWarning) {
+			t.Errorf("%d %s: expected warning %q, got %s", test.statusCode, test.body, test.expectedWarning, logOutput.String())
+		}
+	}
+}
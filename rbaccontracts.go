@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	AuthorizationApiVersion = "2022-04-01"
+
+	// The action the resources and grafana commands need to list the resources on the subscription
+	ReadResourcesAction = "Microsoft.Resources/subscriptions/resources/read"
+)
+
+type RoleAssignment struct {
+	Id         string                   `json:"id"`
+	Name       string                   `json:"name"`
+	Properties RoleAssignmentProperties `json:"properties"`
+}
+
+type RoleAssignmentProperties struct {
+	RoleDefinitionId string `json:"roleDefinitionId"`
+	PrincipalId      string `json:"principalId"`
+	PrincipalType    string `json:"principalType"`
+	Scope            string `json:"scope"`
+}
+
+type RoleDefinition struct {
+	Id         string                   `json:"id"`
+	Name       string                   `json:"name"`
+	Properties RoleDefinitionProperties `json:"properties"`
+}
+
+type RoleDefinitionProperties struct {
+	RoleName    string       `json:"roleName"`
+	Type        string       `json:"type"`
+	Description string       `json:"description"`
+	Permissions []Permission `json:"permissions"`
+}
+
+type Permission struct {
+	Actions        []string `json:"actions"`
+	NotActions     []string `json:"notActions"`
+	DataActions    []string `json:"dataActions"`
+	NotDataActions []string `json:"notDataActions"`
+}
+
+// Role assignment joined with the name of its role definition
+type RoleAssignmentInfo struct {
+	RoleName      string `json:"roleName"`
+	PrincipalId   string `json:"principalId"`
+	PrincipalType string `json:"principalType"`
+	Scope         string `json:"scope"`
+	Id            string `json:"id"`
+}
+
+func (azureClient *AzureClient) getSubscriptionScope() string {
+	return "/subscriptions/" + azureClient.config.Credentials.SubscriptionID
+}
+
+func (azureClient *AzureClient) getRoleAssignments(scope string, maxContinuation int) []RoleAssignment {
+	targetUrl := fmt.Sprintf("%s/providers/Microsoft.Authorization/roleAssignments?api-version=%s", normalizeResourceId(scope), AuthorizationApiVersion)
+
+	roleAssignments := make([]RoleAssignment, 0)
+	for _, value := range azureClient.getPagedValues(targetUrl, maxContinuation) {
+		var roleAssignment RoleAssignment
+		err := json.Unmarshal(value, &roleAssignment)
+		if err != nil {
+			log.Fatalf("Error unmarshalling role assignment response body: %v", err)
+		}
+
+		roleAssignments = append(roleAssignments, roleAssignment)
+	}
+
+	return roleAssignments
+}
+
+func (azureClient *AzureClient) getRoleDefinition(roleDefinitionId string) RoleDefinition {
+	targetUrl := fmt.Sprintf("%s?api-version=%s", roleDefinitionId, AuthorizationApiVersion)
+
+	var roleDefinition RoleDefinition
+	err := json.Unmarshal(azureClient.getResponseBody("GET", targetUrl), &roleDefinition)
+	if err != nil {
+		log.Fatalf("Error unmarshalling role definition response body: %v", err)
+	}
+
+	return roleDefinition
+}
+
+// Get the effective permissions of the configured principal at the scope
+func (azureClient *AzureClient) getPermissions(scope string) []Permission {
+	permissions, err := azureClient.tryGetPermissions(scope)
+	if err != nil {
+		log.Fatalf("Error getting permissions: %v", err)
+	}
+
+	return permissions
+}
+
+// Get the effective permissions, returning an error instead of exiting when the API call fails
+func (azureClient *AzureClient) tryGetPermissions(scope string) ([]Permission, error) {
+	targetUrl := fmt.Sprintf("%s/providers/Microsoft.Authorization/permissions?api-version=%s", normalizeResourceId(scope), AuthorizationApiVersion)
+
+	body, err := azureClient.tryGetJsonResponseBody("GET", targetUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var armListResponse ArmListResponse
+	err = json.Unmarshal(body, &armListResponse)
+	if err != nil {
+		return nil, fmt.Errorf("Error unmarshalling permissions response body: %v", err)
+	}
+
+	permissions := make([]Permission, 0)
+	for _, value := range armListResponse.Values {
+		var permission Permission
+		err := json.Unmarshal(value, &permission)
+		if err != nil {
+			return nil, fmt.Errorf("Error unmarshalling permission response body: %v", err)
+		}
+
+		permissions = append(permissions, permission)
+	}
+
+	return permissions, nil
+}
+
+// Resolve the role definition names of the role assignments.  Role definitions are looked up once each.
+func (azureClient *AzureClient) getRoleAssignmentInfos(roleAssignments []RoleAssignment) []RoleAssignmentInfo {
+	roleNames := make(map[string]string)
+	roleAssignmentInfos := make([]RoleAssignmentInfo, 0)
+	for _, roleAssignment := range roleAssignments {
+		roleDefinitionId := strings.ToLower(roleAssignment.Properties.RoleDefinitionId)
+		roleName, ok := roleNames[roleDefinitionId]
+		if !ok {
+			roleName = azureClient.getRoleDefinition(roleAssignment.Properties.RoleDefinitionId).Properties.RoleName
+			roleNames[roleDefinitionId] = roleName
+		}
+
+		roleAssignmentInfos = append(roleAssignmentInfos, RoleAssignmentInfo{
+			RoleName:      roleName,
+			PrincipalId:   roleAssignment.Properties.PrincipalId,
+			PrincipalType: roleAssignment.Properties.PrincipalType,
+			Scope:         roleAssignment.Properties.Scope,
+			Id:            roleAssignment.Id,
+		})
+	}
+
+	return roleAssignmentInfos
+}
+
+// Returns true if any of the permissions allows the action.  Actions are matched case-insensitively with * wildcards.
+func isActionAllowed(permissions []Permission, action string) bool {
+	for _, permission := range permissions {
+		if matchesAnyAction(permission.Actions, action) && !matchesAnyAction(permission.NotActions, action) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesAnyAction(actionPatterns []string, action string) bool {
+	for _, actionPattern := range actionPatterns {
+		pattern := "(?i)^" + strings.Replace(regexp.QuoteMeta(actionPattern), "\\*", ".*", -1) + "$"
+		if matched, _ := regexp.MatchString(pattern, action); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func getRoleAssignmentInfosOutputTable(roleAssignmentInfos []RoleAssignmentInfo) OutputTable {
+	table := OutputTable{
+		Headers: []string{"RoleName", "PrincipalId", "PrincipalType", "Scope"},
+	}
+
+	for _, roleAssignmentInfo := range roleAssignmentInfos {
+		table.addRow(roleAssignmentInfo.RoleName, roleAssignmentInfo.PrincipalId, roleAssignmentInfo.PrincipalType, roleAssignmentInfo.Scope)
+	}
+
+	return table
+}
+
+func getPermissionsOutputTable(permissions []Permission) OutputTable {
+	table := OutputTable{
+		Headers: []string{"Kind", "Action"},
+	}
+
+	for _, permission := range permissions {
+		for _, action := range permission.Actions {
+			table.addRow("Action", action)
+		}
+
+		for _, action := range permission.NotActions {
+			table.addRow("NotAction", action)
+		}
+
+		for _, action := range permission.DataActions {
+			table.addRow("DataAction", action)
+		}
+
+		for _, action := range permission.NotDataActions {
+			table.addRow("NotDataAction", action)
+		}
+	}
+
+	return table
+}
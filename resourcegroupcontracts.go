@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ResourceGroupsApiVersion = "2021-04-01"
+)
+
+type ResourceGroup struct {
+	Id         string                  `json:"id"`
+	Name       string                  `json:"name"`
+	Location   string                  `json:"location"`
+	ManagedBy  string                  `json:"managedBy,omitempty"`
+	Tags       map[string]string       `json:"tags"`
+	Properties ResourceGroupProperties `json:"properties"`
+}
+
+type ResourceGroupProperties struct {
+	ProvisioningState string `json:"provisioningState"`
+}
+
+type ResourceGroupRequest struct {
+	Location string            `json:"location"`
+	Tags     map[string]string `json:"tags,omitempty"`
+}
+
+type ExportTemplateRequest struct {
+	Resources []string `json:"resources"`
+	Options   string   `json:"options,omitempty"`
+}
+
+type ExportTemplateResult struct {
+	Template json.RawMessage `json:"template"`
+	Error    *ArmError       `json:"error"`
+}
+
+func (azureClient *AzureClient) getResourceGroupUrl(resourceGroupName string) string {
+	return fmt.Sprintf("/subscriptions/%s/resourcegroups/%s", azureClient.config.Credentials.SubscriptionID, resourceGroupName)
+}
+
+func (azureClient *AzureClient) getResourceGroups(maxContinuation int) []ResourceGroup {
+	targetUrl := fmt.Sprintf("/subscriptions/%s/resourcegroups?api-version=%s", azureClient.config.Credentials.SubscriptionID, ResourceGroupsApiVersion)
+
+	resourceGroups := make([]ResourceGroup, 0)
+	for _, value := range azureClient.getPagedValues(targetUrl, maxContinuation) {
+		var resourceGroup ResourceGroup
+		err := json.Unmarshal(value, &resourceGroup)
+		if err != nil {
+			log.Fatalf("Error unmarshalling resource group response body: %v", err)
+		}
+
+		resourceGroups = append(resourceGroups, resourceGroup)
+	}
+
+	sort.Slice(resourceGroups, func(i, j int) bool {
+		return resourceGroups[i].Name < resourceGroups[j].Name
+	})
+
+	return resourceGroups
+}
+
+func (azureClient *AzureClient) getResourceGroup(resourceGroupName string) ResourceGroup {
+	targetUrl := fmt.Sprintf("%s?api-version=%s", azureClient.getResourceGroupUrl(resourceGroupName), ResourceGroupsApiVersion)
+
+	response := azureClient.sendHttpMessage("GET", targetUrl)
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		log.Fatalf("Resource group %s does not exist", resourceGroupName)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		log.Fatalf("Error getting resource group - status code: %d", response.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		log.Fatalf("Error reading body of response: %v", err)
+	}
+
+	return convertToResourceGroup(body)
+}
+
+func (azureClient *AzureClient) createResourceGroup(resourceGroupName string, location string, tags map[string]string) ResourceGroup {
+	targetUrl := fmt.Sprintf("%s?api-version=%s", azureClient.getResourceGroupUrl(resourceGroupName), ResourceGroupsApiVersion)
+
+	request := ResourceGroupRequest{
+		Location: location,
+		Tags:     tags,
+	}
+
+	response := azureClient.sendJsonHttpMessage("PUT", targetUrl, request)
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusCreated {
+		log.Fatalf("Error creating resource group - status code: %d", response.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		log.Fatalf("Error reading body of response: %v", err)
+	}
+
+	return convertToResourceGroup(body)
+}
+
+// Delete the resource group with all its resources and wait for the deletion to complete
+func (azureClient *AzureClient) deleteResourceGroup(resourceGroupName string) {
+	targetUrl := fmt.Sprintf("%s?api-version=%s", azureClient.getResourceGroupUrl(resourceGroupName), ResourceGroupsApiVersion)
+
+	response := azureClient.sendHttpMessage("DELETE", targetUrl)
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusAccepted {
+		log.Fatalf("Error deleting resource group - status code: %d", response.StatusCode)
+	}
+
+	azureClient.waitForAsyncOperation(response)
+}
+
+// Capture the resource group as an ARM template, including all its resources
+func (azureClient *AzureClient) exportResourceGroupTemplate(resourceGroupName string) ExportTemplateResult {
+	targetUrl := fmt.Sprintf("%s/exportTemplate?api-version=%s", azureClient.getResourceGroupUrl(resourceGroupName), ResourceGroupsApiVersion)
+
+	request := ExportTemplateRequest{
+		Resources: []string{"*"},
+		Options:   "IncludeParameterDefaultValue",
+	}
+
+	response := azureClient.sendJsonHttpMessage("POST", targetUrl, request)
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusAccepted {
+		log.Fatalf("Error exporting resource group template - status code: %d", response.StatusCode)
+	}
+
+	var result ExportTemplateResult
+	err := json.Unmarshal(azureClient.waitForAsyncOperation(response), &result)
+	if err != nil {
+		log.Fatalf("Error unmarshalling export template response body: %v", err)
+	}
+
+	return result
+}
+
+func getResourceGroupsOutputTable(resourceGroups []ResourceGroup) OutputTable {
+	table := OutputTable{
+		Headers: []string{"Name", "Location", "ProvisioningState", "Tags"},
+	}
+
+	for _, resourceGroup := range resourceGroups {
+		table.addRow(resourceGroup.Name, resourceGroup.Location, resourceGroup.Properties.ProvisioningState, formatTags(resourceGroup.Tags))
+	}
+
+	return table
+}
+
+func convertToResourceGroup(body []byte) ResourceGroup {
+	var resourceGroup ResourceGroup
+	err := json.Unmarshal(body, &resourceGroup)
+	if err != nil {
+		log.Fatalf("Error unmarshalling resource group response body: %v", err)
+	}
+
+	return resourceGroup
+}
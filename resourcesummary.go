@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Resource counts of one dimension, e.g. location, sorted by count descending
+type ArmResourceSummaryGroup struct {
+	GroupBy string                    `json:"groupBy"`
+	Total   int                       `json:"total"`
+	Counts  []ArmResourceSummaryCount `json:"counts"`
+}
+
+type ArmResourceSummaryCount struct {
+	Value   string  `json:"value"`
+	Count   int     `json:"count"`
+	Percent float64 `json:"percent"`
+}
+
+// The dimensions the resources are counted by.  Resources without a value are counted as "(none)".
+var armResourceSummaryDimensions = []struct {
+	name     string
+	getValue func(armResource ArmResource) string
+}{
+	{"location", func(r ArmResource) string { return r.Location }},
+	{"type", func(r ArmResource) string { return r.Type }},
+	{"kind", func(r ArmResource) string { return r.Kind }},
+	{"skuTier", func(r ArmResource) string { return r.Sku.Tier }},
+	{"resourceGroup", func(r ArmResource) string {
+		resourceGroupName, _ := r.getResourceGroupName()
+		return strings.ToLower(resourceGroupName)
+	}},
+}
+
+func summarizeArmResources(armResources []ArmResource) []ArmResourceSummaryGroup {
+	summaryGroups := make([]ArmResourceSummaryGroup, 0)
+	for _, dimension := range armResourceSummaryDimensions {
+		counts := make(map[string]int)
+		for _, armResource := range armResources {
+			value := dimension.getValue(armResource)
+			if len(value) == 0 {
+				value = "(none)"
+			}
+
+			counts[value]++
+		}
+
+		summaryGroup := ArmResourceSummaryGroup{
+			GroupBy: dimension.name,
+			Total:   len(armResources),
+			Counts:  make([]ArmResourceSummaryCount, 0),
+		}
+
+		for value, count := range counts {
+			summaryGroup.Counts = append(summaryGroup.Counts, ArmResourceSummaryCount{
+				Value:   value,
+				Count:   count,
+				Percent: 100 * float64(count) / float64(len(armResources)),
+			})
+		}
+
+		sort.Slice(summaryGroup.Counts, func(i, j int) bool {
+			if summaryGroup.Counts[i].Count != summaryGroup.Counts[j].Count {
+				return summaryGroup.Counts[i].Count > summaryGroup.Counts[j].Count
+			}
+
+			return summaryGroup.Counts[i].Value < summaryGroup.Counts[j].Value
+		})
+
+		summaryGroups = append(summaryGroups, summaryGroup)
+	}
+
+	return summaryGroups
+}
+
+func getArmResourceSummaryOutputTable(summaryGroups []ArmResourceSummaryGroup) OutputTable {
+	table := OutputTable{
+		Headers: []string{"GroupBy", "Value", "Count", "Percent"},
+	}
+
+	for _, summaryGroup := range summaryGroups {
+		for _, summaryCount := range summaryGroup.Counts {
+			table.addRow(summaryGroup.GroupBy, summaryCount.Value, fmt.Sprintf("%d", summaryCount.Count), fmt.Sprintf("%.1f", summaryCount.Percent))
+		}
+	}
+
+	return table
+}
+
+func printArmResourceSummary(summaryGroups []ArmResourceSummaryGroup) {
+	for _, summaryGroup := range summaryGroups {
+		fmt.Printf("By %s:\n", summaryGroup.GroupBy)
+
+		table := OutputTable{
+			Headers: []string{"  Value", "Count", "Percent"},
+		}
+
+		for _, summaryCount := range summaryGroup.Counts {
+			table.addRow("  "+summaryCount.Value, fmt.Sprintf("%d", summaryCount.Count), fmt.Sprintf("%.1f%%", summaryCount.Percent))
+		}
+
+		table.addRow("  Total", fmt.Sprintf("%d", summaryGroup.Total), "100.0%")
+		printTable(table)
+		fmt.Println()
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	AddedResourceChange   = "added"
+	RemovedResourceChange = "removed"
+	ChangedResourceChange = "changed"
+)
+
+// Point-in-time inventory of the Azure resources on a subscription
+type ArmResourceSnapshot struct {
+	Timestamp      time.Time     `json:"timestamp"`
+	SubscriptionId string        `json:"subscriptionId"`
+	Resources      []ArmResource `json:"resources"`
+}
+
+type ArmResourceChange struct {
+	Change          string                      `json:"change"`
+	Id              string                      `json:"id"`
+	Type            string                      `json:"type"`
+	PropertyChanges []ArmResourcePropertyChange `json:"propertyChanges,omitempty"`
+}
+
+type ArmResourcePropertyChange struct {
+	Property string `json:"property"`
+	Before   string `json:"before"`
+	After    string `json:"after"`
+}
+
+func (snapshot *ArmResourceSnapshot) save(snapshotFile string) {
+	snapshotJson, err := json.MarshalIndent(snapshot, "", " ")
+	if err != nil {
+		log.Fatalf("Error generating snapshot: %v", err)
+	}
+
+	err = ioutil.WriteFile(snapshotFile, snapshotJson, 0644)
+	if err != nil {
+		log.Fatalf("Error writing snapshot file: %v", err)
+	}
+}
+
+func loadArmResourceSnapshot(snapshotFile string) ArmResourceSnapshot {
+	snapshotJson, err := ioutil.ReadFile(snapshotFile)
+	if err != nil {
+		log.Fatalf("Error reading snapshot file: %v", err)
+	}
+
+	var snapshot ArmResourceSnapshot
+	err = json.Unmarshal(snapshotJson, &snapshot)
+	if err != nil {
+		log.Fatalf("Error parsing snapshot file %s: %v", snapshotFile, err)
+	}
+
+	return snapshot
+}
+
+// Compare two snapshots and return the added, removed and changed resources, sorted by resource ID.  Resource IDs are
+// compared case-insensitively since ARM does not preserve casing consistently.
+func diffArmResourceSnapshots(before ArmResourceSnapshot, after ArmResourceSnapshot) []ArmResourceChange {
+	beforeResources := make(map[string]ArmResource)
+	for _, armResource := range before.Resources {
+		beforeResources[strings.ToLower(armResource.Id)] = armResource
+	}
+
+	afterResources := make(map[string]ArmResource)
+	for _, armResource := range after.Resources {
+		afterResources[strings.ToLower(armResource.Id)] = armResource
+	}
+
+	changes := make([]ArmResourceChange, 0)
+	for id, afterResource := range afterResources {
+		beforeResource, ok := beforeResources[id]
+		if !ok {
+			changes = append(changes, ArmResourceChange{Change: AddedResourceChange, Id: afterResource.Id, Type: afterResource.Type})
+			continue
+		}
+
+		propertyChanges := diffArmResources(beforeResource, afterResource)
+		if len(propertyChanges) > 0 {
+			changes = append(changes, ArmResourceChange{Change: ChangedResourceChange, Id: afterResource.Id, Type: afterResource.Type, PropertyChanges: propertyChanges})
+		}
+	}
+
+	for id, beforeResource := range beforeResources {
+		if _, ok := afterResources[id]; !ok {
+			changes = append(changes, ArmResourceChange{Change: RemovedResourceChange, Id: beforeResource.Id, Type: beforeResource.Type})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return strings.ToLower(changes[i].Id) < strings.ToLower(changes[j].Id)
+	})
+
+	return changes
+}
+
+// Compare the SKU, kind, location and tags of the resource
+func diffArmResources(before ArmResource, after ArmResource) []ArmResourcePropertyChange {
+	propertyChanges := make([]ArmResourcePropertyChange, 0)
+	addPropertyChange := func(property string, beforeValue string, afterValue string) {
+		if beforeValue != afterValue {
+			propertyChanges = append(propertyChanges, ArmResourcePropertyChange{Property: property, Before: beforeValue, After: afterValue})
+		}
+	}
+
+	addPropertyChange("location", before.Location, after.Location)
+	addPropertyChange("kind", before.Kind, after.Kind)
+	addPropertyChange("sku.name", before.Sku.Name, after.Sku.Name)
+	addPropertyChange("sku.size", before.Sku.Size, after.Sku.Size)
+	addPropertyChange("sku.tier", before.Sku.Tier, after.Sku.Tier)
+
+	tagKeys := make([]string, 0)
+	for tagKey := range before.Tags {
+		tagKeys = append(tagKeys, tagKey)
+	}
+
+	for tagKey := range after.Tags {
+		if _, ok := before.Tags[tagKey]; !ok {
+			tagKeys = append(tagKeys, tagKey)
+		}
+	}
+
+	sort.Strings(tagKeys)
+	for _, tagKey := range tagKeys {
+		addPropertyChange("tags."+tagKey, before.Tags[tagKey], after.Tags[tagKey])
+	}
+
+	return propertyChanges
+}
+
+func getArmResourceChangesOutputTable(changes []ArmResourceChange) OutputTable {
+	table := OutputTable{
+		Headers: []string{"Change", "Id", "Type", "Property", "Before", "After"},
+	}
+
+	for _, change := range changes {
+		if len(change.PropertyChanges) == 0 {
+			table.addRow(change.Change, change.Id, change.Type, "", "", "")
+		}
+
+		for _, propertyChange := range change.PropertyChanges {
+			table.addRow(change.Change, change.Id, change.Type, propertyChange.Property, propertyChange.Before, propertyChange.After)
+		}
+	}
+
+	return table
+}
+
+func printArmResourceChanges(before ArmResourceSnapshot, after ArmResourceSnapshot, changes []ArmResourceChange) {
+	fmt.Printf("Comparing %s (%s) with %s (%s)\n\n", before.SubscriptionId, before.Timestamp.Format(time.RFC3339), after.SubscriptionId, after.Timestamp.Format(time.RFC3339))
+
+	changeCounts := make(map[string]int)
+	for _, change := range changes {
+		changeCounts[change.Change]++
+
+		switch change.Change {
+		case AddedResourceChange:
+			fmt.Printf("+ %s\n", change.Id)
+		case RemovedResourceChange:
+			fmt.Printf("- %s\n", change.Id)
+		default:
+			fmt.Printf("~ %s\n", change.Id)
+			for _, propertyChange := range change.PropertyChanges {
+				fmt.Printf("    %s: '%s' => '%s'\n", propertyChange.Property, propertyChange.Before, propertyChange.After)
+			}
+		}
+	}
+
+	fmt.Printf("\n%d added, %d removed, %d changed\n", changeCounts[AddedResourceChange], changeCounts[RemovedResourceChange], changeCounts[ChangedResourceChange])
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffArmResourceSnapshots(t *testing.T) {
+	storageId := "/subscriptions/sub1/resourceGroups/rg/providers/Microsoft.Storage/storageAccounts/a"
+	vmId := "/subscriptions/sub1/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/b"
+	storage := ArmResource{Id: storageId, Type: "Microsoft.Storage/storageAccounts", Location: "westus", Kind: "StorageV2", Sku: ArmResourceSku{Name: "Standard_LRS"}, Tags: map[string]string{"env": "dev"}}
+	vm := ArmResource{Id: vmId, Type: "Microsoft.Compute/virtualMachines", Location: "westus"}
+
+	tests := []struct {
+		name     string
+		before   []ArmResource
+		after    []ArmResource
+		expected []ArmResourceChange
+	}{
+		{
+			name:     "unchanged",
+			before:   []ArmResource{storage, vm},
+			after:    []ArmResource{vm, storage},
+			expected: []ArmResourceChange{},
+		},
+		{
+			name:   "added and removed",
+			before: []ArmResource{storage},
+			after:  []ArmResource{vm},
+			expected: []ArmResourceChange{
+				{Change: AddedResourceChange, Id: vmId, Type: vm.Type},
+				{Change: RemovedResourceChange, Id: storageId, Type: storage.Type},
+			},
+		},
+		{
+			name:   "ids compared case-insensitively",
+			before: []ArmResource{storage},
+			after: []ArmResource{func() ArmResource {
+				r := storage
+				r.Id = "/SUBSCRIPTIONS/sub1/resourcegroups/RG/providers/Microsoft.Storage/storageAccounts/a"
+				return r
+			}()},
+			expected: []ArmResourceChange{},
+		},
+		{
+			name:   "changed properties and tags",
+			before: []ArmResource{storage},
+			after: []ArmResource{func() ArmResource {
+				r := storage
+				r.Sku = ArmResourceSku{Name: "Standard_GRS"}
+				r.Tags = map[string]string{"owner": "me"}
+				return r
+			}()},
+			expected: []ArmResourceChange{
+				{Change: ChangedResourceChange, Id: storageId, Type: storage.Type, PropertyChanges: []ArmResourcePropertyChange{
+					{Property: "sku.name", Before: "Standard_LRS", After: "Standard_GRS"},
+					{Property: "tags.env", Before: "dev", After: ""},
+					{Property: "tags.owner", Before: "", After: "me"},
+				}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		changes := diffArmResourceSnapshots(ArmResourceSnapshot{Resources: test.before}, ArmResourceSnapshot{Resources: test.after})
+		if !reflect.DeepEqual(changes, test.expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, changes)
+		}
+	}
+}
+
+func TestArmResourceSnapshotRoundTrip(t *testing.T) {
+	snapshot := ArmResourceSnapshot{
+		Timestamp:      time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		SubscriptionId: "sub1",
+		Resources:      []ArmResource{{Id: "/subscriptions/sub1/resourceGroups/rg/providers/A/b/c", Tags: map[string]string{"k": "v"}}},
+	}
+
+	snapshotFile := filepath.Join(t.TempDir(), "snapshot.json")
+	snapshot.save(snapshotFile)
+	loaded := loadArmResourceSnapshot(snapshotFile)
+	if !reflect.DeepEqual(loaded, snapshot) {
+		t.Errorf("expected %+v, got %+v", snapshot, loaded)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	log "github.com/sirupsen/logrus"
+)
+
+// Each export appends a snapshot, so the resource inventory history can be queried with SQL, e.g.
+//
+//	SELECT r.location, COUNT(*) FROM resources r JOIN skus s ON r.sku_id = s.id
+//	WHERE r.snapshot_id = (SELECT MAX(id) FROM snapshots) AND s.tier = 'Premium' GROUP BY r.location
+var sqliteSchemaStatements = []string{
+	`CREATE TABLE IF NOT EXISTS subscriptions (
+		subscription_id TEXT PRIMARY KEY,
+		display_name TEXT,
+		state TEXT,
+		tenant_id TEXT
+	)`,
+	`CREATE TABLE IF NOT EXISTS snapshots (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		timestamp TEXT NOT NULL,
+		subscription_id TEXT NOT NULL REFERENCES subscriptions(subscription_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS skus (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		size TEXT NOT NULL,
+		tier TEXT NOT NULL,
+		UNIQUE (name, size, tier)
+	)`,
+	`CREATE TABLE IF NOT EXISTS resources (
+		snapshot_id INTEGER NOT NULL REFERENCES snapshots(id),
+		resource_id TEXT NOT NULL,
+		name TEXT,
+		type TEXT,
+		kind TEXT,
+		location TEXT,
+		resource_group TEXT,
+		sku_id INTEGER REFERENCES skus(id),
+		PRIMARY KEY (snapshot_id, resource_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS tags (
+		snapshot_id INTEGER NOT NULL REFERENCES snapshots(id),
+		resource_id TEXT NOT NULL,
+		key TEXT NOT NULL,
+		value TEXT,
+		PRIMARY KEY (snapshot_id, resource_id, key)
+	)`,
+}
+
+// Append the snapshot of the Azure resources to the SQLite database.  Returns the ID of the new snapshot.
+func exportToSqlite(databaseFile string, timestamp time.Time, armSubscription ArmSubscription, armResources []ArmResource) int64 {
+	db, err := sql.Open("sqlite3", databaseFile)
+	if err != nil {
+		log.Fatalf("Error opening SQLite database: %v", err)
+	}
+
+	defer db.Close()
+
+	for _, statement := range sqliteSchemaStatements {
+		if _, err := db.Exec(statement); err != nil {
+			log.Fatalf("Error creating SQLite schema: %v", err)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Fatalf("Error starting SQLite transaction: %v", err)
+	}
+
+	// Rolling back after commit is a no-op
+	defer tx.Rollback()
+
+	mustExec(tx,
+		`INSERT INTO subscriptions (subscription_id, display_name, state, tenant_id) VALUES (?, ?, ?, ?)
+		ON CONFLICT (subscription_id) DO UPDATE SET display_name = excluded.display_name, state = excluded.state, tenant_id = excluded.tenant_id`,
+		armSubscription.SubscriptionId, armSubscription.DisplayName, armSubscription.State, armSubscription.TenantId)
+
+	snapshotId, err := mustExec(tx,
+		`INSERT INTO snapshots (timestamp, subscription_id) VALUES (?, ?)`,
+		timestamp.Format(time.RFC3339), armSubscription.SubscriptionId).LastInsertId()
+	if err != nil {
+		log.Fatalf("Error reading SQLite snapshot ID: %v", err)
+	}
+
+	skuIds := make(map[ArmResourceSku]int64)
+	for _, armResource := range armResources {
+		var skuId sql.NullInt64
+		if armResource.Sku != (ArmResourceSku{}) {
+			skuId = sql.NullInt64{Int64: getSqliteSkuId(tx, skuIds, armResource.Sku), Valid: true}
+		}
+
+		resourceGroupName, _ := armResource.getResourceGroupName()
+		mustExec(tx,
+			`INSERT OR REPLACE INTO resources (snapshot_id, resource_id, name, type, kind, location, resource_group, sku_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			snapshotId, strings.ToLower(armResource.Id), armResource.Name, armResource.Type, armResource.Kind, armResource.Location, resourceGroupName, skuId)
+
+		for key, value := range armResource.Tags {
+			mustExec(tx,
+				`INSERT OR REPLACE INTO tags (snapshot_id, resource_id, key, value) VALUES (?, ?, ?, ?)`,
+				snapshotId, strings.ToLower(armResource.Id), key, value)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Fatalf("Error committing SQLite transaction: %v", err)
+	}
+
+	return snapshotId
+}
+
+// Find or insert the SKU, caching the IDs for the current export
+func getSqliteSkuId(tx *sql.Tx, skuIds map[ArmResourceSku]int64, sku ArmResourceSku) int64 {
+	if skuId, ok := skuIds[sku]; ok {
+		return skuId
+	}
+
+	mustExec(tx, `INSERT OR IGNORE INTO skus (name, size, tier) VALUES (?, ?, ?)`, sku.Name, sku.Size, sku.Tier)
+
+	var skuId int64
+	err := tx.QueryRow(`SELECT id FROM skus WHERE name = ? AND size = ? AND tier = ?`, sku.Name, sku.Size, sku.Tier).Scan(&skuId)
+	if err != nil {
+		log.Fatalf("Error reading SQLite SKU ID: %v", err)
+	}
+
+	skuIds[sku] = skuId
+	return skuId
+}
+
+func mustExec(tx *sql.Tx, statement string, args ...interface{}) sql.Result {
+	result, err := tx.Exec(statement, args...)
+	if err != nil {
+		log.Fatalf("Error writing to SQLite database: %v", err)
+	}
+
+	return result
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExportToSqlite(t *testing.T) {
+	databaseFile := filepath.Join(t.TempDir(), "resources.db")
+	sku := ArmResourceSku{Name: "P1v2", Size: "P1v2", Tier: "PremiumV2"}
+	resourceId := "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Web/serverFarms/Plan1"
+
+	exports := []struct {
+		timestamp    time.Time
+		displayName  string
+		armResources []ArmResource
+	}{
+		{
+			time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+			"Subscription",
+			[]ArmResource{
+				{Id: resourceId, Name: "Plan1", Type: "Microsoft.Web/serverFarms", Location: "westus", Sku: sku, Tags: map[string]string{"env": "prod"}},
+				{Id: "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Web/serverFarms/Plan2", Name: "Plan2", Type: "Microsoft.Web/serverFarms", Location: "eastus", Sku: sku},
+			},
+		},
+		{
+			time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+			"Renamed",
+			[]ArmResource{
+				{Id: resourceId, Name: "Plan1", Type: "Microsoft.Web/serverFarms", Location: "westus", Sku: sku, Tags: map[string]string{"env": "test"}},
+			},
+		},
+	}
+
+	for i, export := range exports {
+		armSubscription := ArmSubscription{SubscriptionId: "sub1", DisplayName: export.displayName, State: "Enabled", TenantId: "tenant1"}
+		if snapshotId := exportToSqlite(databaseFile, export.timestamp, armSubscription, export.armResources); snapshotId != int64(i+1) {
+			t.Errorf("export %d: expected snapshot %d, got %d", i, i+1, snapshotId)
+		}
+	}
+
+	db, err := sql.Open("sqlite3", databaseFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	queryStrings := func(query string) []string {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+
+		defer rows.Close()
+
+		values := make([]string, 0)
+		for rows.Next() {
+			var value string
+			if err := rows.Scan(&value); err != nil {
+				t.Fatalf("%s: %v", query, err)
+			}
+
+			values = append(values, value)
+		}
+
+		return values
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{`SELECT id || ' ' || timestamp FROM snapshots ORDER BY id`, []string{"1 2023-01-01T00:00:00Z", "2 2023-01-02T00:00:00Z"}},
+		{`SELECT display_name FROM subscriptions`, []string{"Renamed"}},
+		{`SELECT name || ' ' || size || ' ' || tier FROM skus`, []string{"P1v2 P1v2 PremiumV2"}},
+		{`SELECT snapshot_id || ' ' || name || ' ' || resource_group || ' ' || sku_id FROM resources ORDER BY snapshot_id, name`, []string{"1 Plan1 rg1 1", "1 Plan2 rg1 1", "2 Plan1 rg1 1"}},
+		{`SELECT snapshot_id || ' ' || resource_id || ' ' || key || '=' || value FROM tags ORDER BY snapshot_id`, []string{
+			"1 /subscriptions/sub1/resourcegroups/rg1/providers/microsoft.web/serverfarms/plan1 env=prod",
+			"2 /subscriptions/sub1/resourcegroups/rg1/providers/microsoft.web/serverfarms/plan1 env=test",
+		}},
+	}
+
+	for _, test := range tests {
+		if values := queryStrings(test.query); !reflect.DeepEqual(values, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.query, test.expected, values)
+		}
+	}
+}
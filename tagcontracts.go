@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	TagsApiVersion = "2021-04-01"
+
+	MergeTagOperation   = "Merge"
+	ReplaceTagOperation = "Replace"
+	DeleteTagOperation  = "Delete"
+)
+
+type TagsPatchRequest struct {
+	Operation  string        `json:"operation"`
+	Properties TagProperties `json:"properties"`
+}
+
+type TagProperties struct {
+	Tags map[string]string `json:"tags"`
+}
+
+// Tag change that failed to apply
+type TagChangeFailure struct {
+	Change TagChange
+	Err    error
+}
+
+// Planned tag change of a resource.  Tags holds the tags sent to the Tags API for the operation.
+type TagChange struct {
+	ResourceId string            `json:"resourceId"`
+	Operation  string            `json:"operation"`
+	Tags       map[string]string `json:"tags"`
+	Before     map[string]string `json:"before"`
+	After      map[string]string `json:"after"`
+}
+
+// Patch the tags of the resource through the Tags API.  Returns an error with the ARM error message instead of exiting or
+// logging, so bulk updates can report every failure.
+func (azureClient *AzureClient) patchTags(resourceId string, operation string, tags map[string]string) error {
+	targetUrl := fmt.Sprintf("%s/providers/Microsoft.Resources/tags/default?api-version=%s", normalizeResourceId(resourceId), TagsApiVersion)
+
+	request := TagsPatchRequest{
+		Operation:  operation,
+		Properties: TagProperties{Tags: tags},
+	}
+
+	_, err := azureClient.tryGetJsonResponseBody("PATCH", targetUrl, request)
+	return err
+}
+
+// Plan setting the tags on the resource.  Merge keeps the other existing tags, replace removes them.  Returns false if
+// the resource already has the resulting tags.
+func planSetTags(armResource ArmResource, tags map[string]string, operation string) (TagChange, bool) {
+	after := make(map[string]string)
+	if operation == MergeTagOperation {
+		for key, value := range armResource.Tags {
+			after[key] = value
+		}
+	}
+
+	for key, value := range tags {
+		// Tag keys are case-insensitive, so overwrite the existing key rather than adding a second one
+		for existingKey := range after {
+			if strings.EqualFold(existingKey, key) {
+				delete(after, existingKey)
+			}
+		}
+
+		after[key] = value
+	}
+
+	change := TagChange{
+		ResourceId: armResource.Id,
+		Operation:  operation,
+		Tags:       tags,
+		Before:     armResource.Tags,
+		After:      after,
+	}
+
+	return change, !areTagsEqual(armResource.Tags, after)
+}
+
+// Plan removing the tag keys from the resource.  The Tags API deletes tags by name and value, so the values are taken
+// from the existing tags.  Returns false if the resource has none of the keys.
+func planRemoveTags(armResource ArmResource, keys []string) (TagChange, bool) {
+	removedTags := make(map[string]string)
+	after := make(map[string]string)
+	for key, value := range armResource.Tags {
+		if containsIgnoreCase(keys, key) {
+			removedTags[key] = value
+		} else {
+			after[key] = value
+		}
+	}
+
+	change := TagChange{
+		ResourceId: armResource.Id,
+		Operation:  DeleteTagOperation,
+		Tags:       removedTags,
+		Before:     armResource.Tags,
+		After:      after,
+	}
+
+	return change, len(removedTags) > 0
+}
+
+func areTagsEqual(tags map[string]string, otherTags map[string]string) bool {
+	if len(tags) != len(otherTags) {
+		return false
+	}
+
+	for key, value := range tags {
+		if otherValue, ok := otherTags[key]; !ok || otherValue != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func formatTags(tags map[string]string) string {
+	keys := make([]string, 0)
+	for key := range tags {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	formattedTags := make([]string, 0)
+	for _, key := range keys {
+		formattedTags = append(formattedTags, key+"="+tags[key])
+	}
+
+	return "{" + strings.Join(formattedTags, ", ") + "}"
+}
+
+// Apply the tag changes with at most concurrency changes sent at the same time.  Returns the failed changes sorted by
+// resource ID.
+func (azureClient *AzureClient) applyTagChanges(changes []TagChange, concurrency int) []TagChangeFailure {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// Get the access token before fanning out so the workers do not race to set it
+	azureClient.ensureAccessTokenSet()
+
+	changesChannel := make(chan TagChange)
+	failuresChannel := make(chan TagChangeFailure)
+	var waitGroup sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for change := range changesChannel {
+				err := azureClient.patchTags(change.ResourceId, change.Operation, change.Tags)
+				if err != nil {
+					failuresChannel <- TagChangeFailure{Change: change, Err: err}
+				}
+			}
+		}()
+	}
+
+	go func() {
+		for _, change := range changes {
+			changesChannel <- change
+		}
+
+		close(changesChannel)
+		waitGroup.Wait()
+		close(failuresChannel)
+	}()
+
+	failures := make([]TagChangeFailure, 0)
+	for failure := range failuresChannel {
+		failures = append(failures, failure)
+	}
+
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].Change.ResourceId < failures[j].Change.ResourceId
+	})
+
+	return failures
+}
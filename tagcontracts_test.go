@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPlanSetTags(t *testing.T) {
+	tests := []struct {
+		name          string
+		existingTags  map[string]string
+		tags          map[string]string
+		operation     string
+		expectedAfter map[string]string
+		expectedOk    bool
+	}{
+		{"merge adds", map[string]string{"a": "1"}, map[string]string{"b": "2"}, MergeTagOperation, map[string]string{"a": "1", "b": "2"}, true},
+		{"merge overwrites case-insensitively", map[string]string{"Owner": "x"}, map[string]string{"owner": "y"}, MergeTagOperation, map[string]string{"owner": "y"}, true},
+		{"merge unchanged", map[string]string{"a": "1", "b": "2"}, map[string]string{"a": "1"}, MergeTagOperation, map[string]string{"a": "1", "b": "2"}, false},
+		{"merge into no tags", nil, map[string]string{"a": "1"}, MergeTagOperation, map[string]string{"a": "1"}, true},
+		{"replace removes others", map[string]string{"a": "1", "b": "2"}, map[string]string{"a": "1"}, ReplaceTagOperation, map[string]string{"a": "1"}, true},
+		{"replace unchanged", map[string]string{"a": "1"}, map[string]string{"a": "1"}, ReplaceTagOperation, map[string]string{"a": "1"}, false},
+	}
+
+	for _, test := range tests {
+		armResource := ArmResource{Id: "/subscriptions/sub1/resourceGroups/rg/providers/A/b/c", Tags: test.existingTags}
+		change, ok := planSetTags(armResource, test.tags, test.operation)
+		if ok != test.expectedOk || !reflect.DeepEqual(change.After, test.expectedAfter) {
+			t.Errorf("%s: expected %v %t, got %v %t", test.name, test.expectedAfter, test.expectedOk, change.After, ok)
+		}
+
+		if change.Operation != test.operation || !reflect.DeepEqual(change.Tags, test.tags) || change.ResourceId != armResource.Id {
+			t.Errorf("%s: unexpected change %+v", test.name, change)
+		}
+	}
+}
+
+func TestPlanRemoveTags(t *testing.T) {
+	tests := []struct {
+		name            string
+		existingTags    map[string]string
+		keys            []string
+		expectedRemoved map[string]string
+		expectedAfter   map[string]string
+		expectedOk      bool
+	}{
+		{"removes with values", map[string]string{"a": "1", "b": "2"}, []string{"a"}, map[string]string{"a": "1"}, map[string]string{"b": "2"}, true},
+		{"case-insensitive", map[string]string{"Owner": "x"}, []string{"owner"}, map[string]string{"Owner": "x"}, map[string]string{}, true},
+		{"missing key", map[string]string{"a": "1"}, []string{"b"}, map[string]string{}, map[string]string{"a": "1"}, false},
+	}
+
+	for _, test := range tests {
+		change, ok := planRemoveTags(ArmResource{Tags: test.existingTags}, test.keys)
+		if ok != test.expectedOk || change.Operation != DeleteTagOperation || !reflect.DeepEqual(change.Tags, test.expectedRemoved) || !reflect.DeepEqual(change.After, test.expectedAfter) {
+			t.Errorf("%s: unexpected change %+v %t", test.name, change, ok)
+		}
+	}
+}
+
+func TestApplyTagChanges(t *testing.T) {
+	var requestCount int32
+	azureClient := newTestAzureClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requestCount, 1)
+		if r.Method != "PATCH" || !strings.HasSuffix(r.URL.Path, "/providers/Microsoft.Resources/tags/default") {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		var request TagsPatchRequest
+		if err := json.Unmarshal(body, &request); err != nil || request.Operation != MergeTagOperation {
+			t.Errorf("unexpected request body %s", body)
+		}
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/locked/providers/Microsoft.Resources/tags/default"):
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"error":{"code":"ScopeLocked","message":"The scope is locked."}}`))
+		case strings.HasSuffix(r.URL.Path, "/broken/providers/Microsoft.Resources/tags/default"):
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`<html>Bad Gateway</html>`))
+		default:
+			w.Write(body)
+		}
+	})
+
+	changes := make([]TagChange, 0)
+	for _, name := range []string{"a", "locked", "b", "broken", "c"} {
+		changes = append(changes, TagChange{
+			ResourceId: "/subscriptions/sub1/resourceGroups/rg/providers/A/b/" + name,
+			Operation:  MergeTagOperation,
+			Tags:       map[string]string{"k": "v"},
+		})
+	}
+
+	failures := azureClient.applyTagChanges(changes, 3)
+	if requestCount != 5 {
+		t.Errorf("expected 5 requests, got %d", requestCount)
+	}
+
+	if len(failures) != 2 {
+		t.Fatalf("expected 2 failures, got %+v", failures)
+	}
+
+	expectedErrors := []string{"status code 502", "status code 409: The scope is locked."}
+	for index, failure := range failures {
+		if failure.Err.Error() != expectedErrors[index] {
+			t.Errorf("%s: expected error %q, got %q", failure.Change.ResourceId, expectedErrors[index], failure.Err)
+		}
+	}
+}
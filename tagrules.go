@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	JUnitOutputFormat = "junit"
+)
+
+// Tag compliance rules, e.g.
+//
+//	exempt_types:
+//	  - Microsoft.Network/networkWatchers
+//	rules:
+//	  - key: owner
+//	    required: true
+//	    pattern: "^[a-z.]+@contoso\\.com$"
+//	  - key: environment
+//	    allowed_values: [dev, test, prod]
+//	    exempt_types:
+//	      - Microsoft.Storage/storageAccounts
+type TagRuleFile struct {
+	ExemptTypes []string  `yaml:"exempt_types"`
+	Rules       []TagRule `yaml:"rules"`
+
+	XXX map[string]interface{} `yaml:",inline"`
+}
+
+type TagRule struct {
+	Key           string   `yaml:"key"`
+	Required      bool     `yaml:"required"`
+	AllowedValues []string `yaml:"allowed_values"`
+	Pattern       string   `yaml:"pattern"`
+	ExemptTypes   []string `yaml:"exempt_types"`
+
+	XXX map[string]interface{} `yaml:",inline"`
+
+	patternRegexp *regexp.Regexp
+}
+
+type TagViolation struct {
+	ResourceId   string `json:"resourceId"`
+	ResourceType string `json:"resourceType"`
+	Key          string `json:"key"`
+	Value        string `json:"value"`
+	Problem      string `json:"problem"`
+}
+
+type JUnitTestSuites struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	TestSuites []JUnitTestSuite `xml:"testsuite"`
+}
+
+type JUnitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []JUnitTestCase `xml:"testcase"`
+}
+
+type JUnitTestCase struct {
+	Name      string         `xml:"name,attr"`
+	ClassName string         `xml:"classname,attr"`
+	Failures  []JUnitFailure `xml:"failure"`
+}
+
+type JUnitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+}
+
+func loadTagRuleFile(ruleFile string) (*TagRuleFile, error) {
+	yamlFile, err := ioutil.ReadFile(ruleFile)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading tag rule file: %s", err)
+	}
+
+	tagRuleFile := &TagRuleFile{}
+	if err := yaml.Unmarshal(yamlFile, tagRuleFile); err != nil {
+		return nil, fmt.Errorf("Error parsing tag rule file: %s", err)
+	}
+
+	for index := range tagRuleFile.Rules {
+		tagRule := &tagRuleFile.Rules[index]
+		if len(tagRule.Key) == 0 {
+			return nil, fmt.Errorf("Error parsing tag rule file: rule %d has no key", index+1)
+		}
+
+		if len(tagRule.Pattern) > 0 {
+			tagRule.patternRegexp, err = regexp.Compile(tagRule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("Error parsing pattern of tag rule %s: %s", tagRule.Key, err)
+			}
+		}
+	}
+
+	return tagRuleFile, nil
+}
+
+// Evaluate the rules against the tags of the resource.  Tag keys are case-insensitive in ARM.
+func (tagRuleFile *TagRuleFile) evaluate(armResource ArmResource) []TagViolation {
+	violations := make([]TagViolation, 0)
+	if containsIgnoreCase(tagRuleFile.ExemptTypes, armResource.Type) {
+		return violations
+	}
+
+	for _, tagRule := range tagRuleFile.Rules {
+		if containsIgnoreCase(tagRule.ExemptTypes, armResource.Type) {
+			continue
+		}
+
+		addViolation := func(value string, problem string) {
+			violations = append(violations, TagViolation{
+				ResourceId:   armResource.Id,
+				ResourceType: armResource.Type,
+				Key:          tagRule.Key,
+				Value:        value,
+				Problem:      problem,
+			})
+		}
+
+		value, ok := armResource.getTagValue(tagRule.Key)
+		if !ok {
+			if tagRule.Required {
+				addViolation("", "required tag is missing")
+			}
+
+			continue
+		}
+
+		if len(tagRule.AllowedValues) > 0 && !containsIgnoreCase(tagRule.AllowedValues, value) {
+			addViolation(value, fmt.Sprintf("value is not one of: %s", strings.Join(tagRule.AllowedValues, ", ")))
+		}
+
+		if tagRule.patternRegexp != nil && !tagRule.patternRegexp.MatchString(value) {
+			addViolation(value, fmt.Sprintf("value does not match pattern %s", tagRule.Pattern))
+		}
+	}
+
+	return violations
+}
+
+func getTagViolationsOutputTable(violations []TagViolation) OutputTable {
+	table := OutputTable{
+		Headers: []string{"ResourceId", "ResourceType", "Key", "Value", "Problem"},
+	}
+
+	for _, violation := range violations {
+		table.addRow(violation.ResourceId, violation.ResourceType, violation.Key, violation.Value, violation.Problem)
+	}
+
+	return table
+}
+
+// Generate the JUnit XML report with one test case per resource and one failure per violation
+func getTagAuditJUnitReport(armResources []ArmResource, violationsByResourceId map[string][]TagViolation) JUnitTestSuites {
+	testSuite := JUnitTestSuite{
+		Name:      "tags audit",
+		TestCases: make([]JUnitTestCase, 0),
+	}
+
+	for _, armResource := range armResources {
+		testCase := JUnitTestCase{
+			Name:      armResource.Id,
+			ClassName: armResource.Type,
+		}
+
+		for _, violation := range violationsByResourceId[armResource.Id] {
+			testCase.Failures = append(testCase.Failures, JUnitFailure{
+				Message: fmt.Sprintf("tag %s: %s", violation.Key, violation.Problem),
+				Type:    "TagViolation",
+			})
+		}
+
+		testSuite.Tests++
+		if len(testCase.Failures) > 0 {
+			testSuite.Failures++
+		}
+
+		testSuite.TestCases = append(testSuite.TestCases, testCase)
+	}
+
+	return JUnitTestSuites{TestSuites: []JUnitTestSuite{testSuite}}
+}
+
+// Serialize the JUnit XML report, including the XML header
+func getTagAuditJUnitReportXml(armResources []ArmResource, violationsByResourceId map[string][]TagViolation) []byte {
+	report, err := xml.MarshalIndent(getTagAuditJUnitReport(armResources, violationsByResourceId), "", "  ")
+	if err != nil {
+		log.Fatalf("Error generating JUnit report: %v", err)
+	}
+
+	return []byte(xml.Header + string(report) + "\n")
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (s *TagRuleFile) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain TagRuleFile
+	if err := unmarshal((*plain)(s)); err != nil {
+		return err
+	}
+	if err := checkOverflow(s.XXX, "tag rule file"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (s *TagRule) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain TagRule
+	if err := unmarshal((*plain)(s)); err != nil {
+		return err
+	}
+	if err := checkOverflow(s.XXX, "tag rule"); err != nil {
+		return err
+	}
+	return nil
+}
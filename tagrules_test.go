@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testTagRuleFile = `
+exempt_types:
+  - Microsoft.Network/networkWatchers
+rules:
+  - key: owner
+    required: true
+    pattern: "^[a-z.]+@contoso\\.com$"
+  - key: environment
+    allowed_values: [dev, test, prod]
+    exempt_types:
+      - Microsoft.Storage/storageAccounts
+`
+
+func writeTestTagRuleFile(t *testing.T, contents string) string {
+	ruleFile := filepath.Join(t.TempDir(), "rules.yml")
+	err := ioutil.WriteFile(ruleFile, []byte(contents), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ruleFile
+}
+
+func TestLoadTagRuleFile(t *testing.T) {
+	tests := []struct {
+		contents      string
+		expectedError string
+	}{
+		{testTagRuleFile, ""},
+		{"rules:\n  - required: true\n", "rule 1 has no key"},
+		{"rules:\n  - key: owner\n    pattern: \"[\"\n", "Error parsing pattern of tag rule owner"},
+		{"rules:\n  - key: owner\n    requried: true\n", "unknown fields in tag rule: requried"},
+		{"rule:\n  - key: owner\n", "unknown fields in tag rule file: rule"},
+	}
+
+	for _, test := range tests {
+		_, err := loadTagRuleFile(writeTestTagRuleFile(t, test.contents))
+		if len(test.expectedError) == 0 && err != nil {
+			t.Errorf("%q: unexpected error %v", test.contents, err)
+		}
+
+		if len(test.expectedError) > 0 && (err == nil || !strings.Contains(err.Error(), test.expectedError)) {
+			t.Errorf("%q: expected error containing %q, got %v", test.contents, test.expectedError, err)
+		}
+	}
+}
+
+func TestEvaluateTagRules(t *testing.T) {
+	tagRuleFile, err := loadTagRuleFile(writeTestTagRuleFile(t, testTagRuleFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		resourceType     string
+		tags             map[string]string
+		expectedProblems []string
+	}{
+		{"Microsoft.Compute/virtualMachines", map[string]string{"owner": "jo@contoso.com", "environment": "dev"}, []string{}},
+		{"Microsoft.Compute/virtualMachines", map[string]string{"OWNER": "jo@contoso.com", "Environment": "PROD"}, []string{}},
+		{"Microsoft.Compute/virtualMachines", map[string]string{}, []string{"owner: required tag is missing"}},
+		{"Microsoft.Compute/virtualMachines", map[string]string{"owner": "jo@example.com", "environment": "staging"}, []string{
+			"owner: value does not match pattern ^[a-z.]+@contoso\\.com$",
+			"environment: value is not one of: dev, test, prod",
+		}},
+		{"Microsoft.Storage/storageAccounts", map[string]string{"owner": "jo@contoso.com", "environment": "staging"}, []string{}},
+		{"microsoft.network/networkwatchers", map[string]string{}, []string{}},
+	}
+
+	for _, test := range tests {
+		armResource := ArmResource{Id: "/subscriptions/sub1/resourceGroups/rg/providers/" + test.resourceType + "/a", Type: test.resourceType, Tags: test.tags}
+		problems := make([]string, 0)
+		for _, violation := range tagRuleFile.evaluate(armResource) {
+			if violation.ResourceId != armResource.Id || violation.ResourceType != test.resourceType {
+				t.Errorf("unexpected violation resource %+v", violation)
+			}
+
+			problems = append(problems, violation.Key+": "+violation.Problem)
+		}
+
+		if !reflect.DeepEqual(problems, test.expectedProblems) {
+			t.Errorf("%s %v: expected %v, got %v", test.resourceType, test.tags, test.expectedProblems, problems)
+		}
+	}
+}
+
+func TestTagAuditJUnitReport(t *testing.T) {
+	armResources := []ArmResource{
+		{Id: "/subscriptions/sub1/resourceGroups/rg/providers/A/b/compliant", Type: "A/b"},
+		{Id: "/subscriptions/sub1/resourceGroups/rg/providers/A/b/noncompliant", Type: "A/b"},
+	}
+
+	violationsByResourceId := map[string][]TagViolation{
+		armResources[1].Id: {{Key: "owner", Problem: "required tag is missing"}, {Key: "environment", Problem: "value is not one of: dev"}},
+	}
+
+	report := getTagAuditJUnitReportXml(armResources, violationsByResourceId)
+	if !strings.HasPrefix(string(report), xml.Header) {
+		t.Errorf("report lacks the XML header: %s", report)
+	}
+
+	var parsed JUnitTestSuites
+	err := xml.Unmarshal(report, &parsed)
+	if err != nil {
+		t.Fatalf("report does not parse: %v\n%s", err, report)
+	}
+
+	testSuite := parsed.TestSuites[0]
+	if testSuite.Tests != 2 || testSuite.Failures != 1 || len(testSuite.TestCases[0].Failures) != 0 || len(testSuite.TestCases[1].Failures) != 2 {
+		t.Errorf("unexpected report %+v", testSuite)
+	}
+
+	if testSuite.TestCases[1].Failures[0].Message != "tag owner: required tag is missing" {
+		t.Errorf("unexpected failure message %q", testSuite.TestCases[1].Failures[0].Message)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Evaluate the tag rules against the Azure resources on the subscription and exit with non-zero code on violations.
+// The JUnit report is also written to junitFile, if any, so CI pipelines get it regardless of the output format.
+func (processor *CommandProcessor) processTagsAuditCommand(maxContinuation int, filter ArmResourceFilter, ruleFile string, outputFormat string, junitFile string) {
+	tagRuleFile, err := loadTagRuleFile(ruleFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	armResources := processor.getSelectedAzureResources(maxContinuation, filter)
+
+	violations := make([]TagViolation, 0)
+	violationsByResourceId := make(map[string][]TagViolation)
+	for _, armResource := range armResources {
+		resourceViolations := tagRuleFile.evaluate(armResource)
+		if len(resourceViolations) > 0 {
+			violations = append(violations, resourceViolations...)
+			violationsByResourceId[armResource.Id] = resourceViolations
+		}
+	}
+
+	if len(junitFile) > 0 {
+		err = ioutil.WriteFile(junitFile, getTagAuditJUnitReportXml(armResources, violationsByResourceId), 0644)
+		if err != nil {
+			log.Fatalf("Error writing JUnit report: %v", err)
+		}
+
+		log.Infof("Created %s", junitFile)
+	}
+
+	switch outputFormat {
+	case JUnitOutputFormat:
+		fmt.Print(string(getTagAuditJUnitReportXml(armResources, violationsByResourceId)))
+	case TextOutputFormat:
+		printTagViolations(violations, len(armResources), len(violationsByResourceId))
+	default:
+		printOutput(outputFormat, getTagViolationsOutputTable(violations), violations)
+	}
+
+	if len(violations) > 0 {
+		os.Exit(1)
+	}
+}
+
+func printTagViolations(violations []TagViolation, resourceCount int, nonCompliantResourceCount int) {
+	resourceId := ""
+	for _, violation := range violations {
+		if violation.ResourceId != resourceId {
+			resourceId = violation.ResourceId
+			fmt.Printf("Id: %s:\n", resourceId)
+		}
+
+		if len(violation.Value) > 0 {
+			fmt.Printf("  Tag %s = '%s': %s\n", violation.Key, violation.Value, violation.Problem)
+		} else {
+			fmt.Printf("  Tag %s: %s\n", violation.Key, violation.Problem)
+		}
+	}
+
+	fmt.Printf("\n%d of %d resources are non-compliant, %d violations found\n", nonCompliantResourceCount, resourceCount, len(violations))
+}
+
+// Apply the planned tag changes, or only print them on dry run.  Only the summary of the changes is printed when they
+// are applied.
+func (processor *CommandProcessor) processTagChanges(changes []TagChange, selectedCount int, isDryRun bool, concurrency int) {
+	if isDryRun {
+		for _, change := range changes {
+			fmt.Printf("%s:\n  %s => %s\n", change.ResourceId, formatTags(change.Before), formatTags(change.After))
+		}
+
+		fmt.Printf("\nDry run: %d of %d selected resources would be updated\n", len(changes), selectedCount)
+		return
+	}
+
+	failures := processor.azureClient.applyTagChanges(changes, concurrency)
+
+	fmt.Printf("%d updated, %d failed, %d unchanged\n", len(changes)-len(failures), len(failures), selectedCount-len(changes))
+	for _, failure := range failures {
+		fmt.Printf("  Failed %s: %v\n", failure.Change.ResourceId, failure.Err)
+	}
+
+	if len(failures) > 0 {
+		os.Exit(1)
+	}
+}
+
+func (processor *CommandProcessor) processTagsSetCommand(maxContinuation int, filter ArmResourceFilter, tags map[string]string, operation string, isDryRun bool, concurrency int) {
+	armResources := processor.getSelectedAzureResources(maxContinuation, filter)
+
+	changes := make([]TagChange, 0)
+	for _, armResource := range armResources {
+		if change, ok := planSetTags(armResource, tags, operation); ok {
+			changes = append(changes, change)
+		}
+	}
+
+	processor.processTagChanges(changes, len(armResources), isDryRun, concurrency)
+}
+
+func (processor *CommandProcessor) processTagsRemoveCommand(maxContinuation int, filter ArmResourceFilter, keys []string, isDryRun bool, concurrency int) {
+	armResources := processor.getSelectedAzureResources(maxContinuation, filter)
+
+	changes := make([]TagChange, 0)
+	for _, armResource := range armResources {
+		if change, ok := planRemoveTags(armResource, keys); ok {
+			changes = append(changes, change)
+		}
+	}
+
+	processor.processTagChanges(changes, len(armResources), isDryRun, concurrency)
+}
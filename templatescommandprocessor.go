@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Fetch the templates of every resource type of the source into the template cache
+func processTemplatesSyncCommand(templateSource GrafanaTemplateSource) {
+	cachedSource, ok := templateSource.(*CachedGrafanaTemplateSource)
+	if !ok {
+		log.Fatalf("Templates in %s are read directly and are not cached", templateSource)
+	}
+
+	templateDirs, err := cachedSource.source.getTemplateDirs()
+	if err != nil {
+		log.Fatalf("Error listing the resource types of %s: %v", templateSource, err)
+	}
+
+	templateCount := 0
+	for _, templateDir := range templateDirs {
+		dashboardTemplates, err := cachedSource.getTemplates(templateDir)
+		if err != nil {
+			log.Fatalf("Error reading templates of %s from %s: %v", templateDir, templateSource, err)
+		}
+
+		log.Infof("%s: %d templates", templateDir, len(dashboardTemplates))
+		templateCount += len(dashboardTemplates)
+	}
+
+	fmt.Printf("%d templates of %d resource types from %s cached in %s\n", templateCount, len(templateDirs), templateSource, cachedSource.cache.getSourceDir(templateSource))
+}